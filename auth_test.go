@@ -2,6 +2,7 @@ package main
 
 import (
 	"crypto/sha256"
+	"database/sql"
 	"encoding/base64"
 	"encoding/json"
 	"net/http"
@@ -9,6 +10,8 @@ import (
 	"os"
 	"strings"
 	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
 )
 
 // ==========================
@@ -156,9 +159,21 @@ func TestAuthMiddleware(t *testing.T) {
 // ==========================
 
 func TestHandleSignin(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := createUsersTable(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := createLoginAuditTable(db); err != nil {
+		t.Fatal(err)
+	}
+
 	// Подготовим хендлер
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		handleSignin(w, r)
+		handleSignin(w, r, db)
 	})
 
 	t.Run("пароль не установлен => вернуть ошибку", func(t *testing.T) {
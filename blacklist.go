@@ -0,0 +1,189 @@
+package main
+
+import (
+	"container/list"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+)
+
+// blacklistCacheSize — сколько записей "jti -> отозван" держим в памяти
+// перед тем, как лезть в БД. Для однопроцессного TODO-сервера этого с
+// запасом хватает на все активные сессии.
+const blacklistCacheSize = 1000
+
+// jtiLRUCache — минимальный LRU поверх container/list: хранит только
+// "да, этот jti отозван", используется как кэш перед BlacklistStore.
+type jtiLRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+func newJTILRUCache(capacity int) *jtiLRUCache {
+	return &jtiLRUCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *jtiLRUCache) Add(jti string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[jti]; ok {
+		c.ll.MoveToFront(el)
+		return
+	}
+	el := c.ll.PushFront(jti)
+	c.items[jti] = el
+
+	if c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		if oldest != nil {
+			c.ll.Remove(oldest)
+			delete(c.items, oldest.Value.(string))
+		}
+	}
+}
+
+func (c *jtiLRUCache) Contains(jti string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[jti]
+	if ok {
+		c.ll.MoveToFront(el)
+	}
+	return ok
+}
+
+func (c *jtiLRUCache) Remove(jti string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[jti]; ok {
+		c.ll.Remove(el)
+		delete(c.items, jti)
+	}
+}
+
+// blacklistStore хранит отозванные jti: таблица blacklist(jti, exp) в
+// scheduler.db плюс LRU-кэш перед ней, чтобы проверка токена на каждый
+// запрос не всегда ходила в SQLite.
+type blacklistStore struct {
+	db    *sql.DB
+	cache *jtiLRUCache
+}
+
+func createBlacklistTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS blacklist (
+			jti VARCHAR(64) PRIMARY KEY,
+			exp INTEGER NOT NULL
+		);`)
+	return err
+}
+
+func newBlacklistStore(db *sql.DB) *blacklistStore {
+	return &blacklistStore{db: db, cache: newJTILRUCache(blacklistCacheSize)}
+}
+
+// Revoke добавляет jti в чёрный список — как в БД (переживает перезапуск),
+// так и в кэш (чтобы следующая же проверка этого токена не шла в БД).
+func (b *blacklistStore) Revoke(jti string, exp int64) error {
+	if jti == "" {
+		return nil
+	}
+	_, err := b.db.Exec(`INSERT OR REPLACE INTO blacklist (jti, exp) VALUES (?, ?)`, jti, exp)
+	if err != nil {
+		return err
+	}
+	b.cache.Add(jti)
+	return nil
+}
+
+// IsRevoked сначала смотрит в кэш и только при промахе — в БД. Найденный
+// в БД отозванный jti тоже кладётся в кэш, чтобы следующий запрос с тем
+// же токеном не повторял запрос к БД.
+func (b *blacklistStore) IsRevoked(jti string) bool {
+	if jti == "" {
+		return false
+	}
+	if b.cache.Contains(jti) {
+		return true
+	}
+
+	var exists int
+	err := b.db.QueryRow(`SELECT 1 FROM blacklist WHERE jti = ?`, jti).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		log.Println("Ошибка проверки чёрного списка токенов:", err)
+		return false
+	}
+	b.cache.Add(jti)
+	return true
+}
+
+// Sweep удаляет из БД записи с истёкшим exp, чтобы таблица blacklist не
+// росла бесконечно — отозванный токен всё равно перестаёт быть валидным
+// по exp, так что хранить его дольше смысла нет.
+func (b *blacklistStore) Sweep(now time.Time) (int64, error) {
+	res, err := b.db.Exec(`DELETE FROM blacklist WHERE exp < ?`, now.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// startBlacklistSweeper запускает фоновую горутину, которая периодически
+// чистит протухшие записи. Возвращает функцию остановки (для тестов).
+func startBlacklistSweeper(b *blacklistStore, interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if n, err := b.Sweep(time.Now()); err != nil {
+					log.Println("Ошибка очистки чёрного списка токенов:", err)
+				} else if n > 0 {
+					log.Printf("Чёрный список: удалено %d просроченных записей\n", n)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// currentBlacklist — глобальный экземпляр, инициализируемый в main().
+// Пакетные функции (parseAndVerifyJWTAt) обращаются к нему через
+// getBlacklistStore, чтобы не протаскивать *sql.DB через каждую сигнатуру
+// в jwt.go — как и с keyStore, это намеренный компромисс для
+// однопроцессного сервера.
+var (
+	blacklistMu      sync.RWMutex
+	currentBlacklist *blacklistStore
+)
+
+func setBlacklistStore(b *blacklistStore) {
+	blacklistMu.Lock()
+	defer blacklistMu.Unlock()
+	currentBlacklist = b
+}
+
+func getBlacklistStore() *blacklistStore {
+	blacklistMu.RLock()
+	defer blacklistMu.RUnlock()
+	return currentBlacklist
+}
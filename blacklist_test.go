@@ -0,0 +1,114 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestBlacklistStore(t *testing.T) *blacklistStore {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := createBlacklistTable(db); err != nil {
+		t.Fatal(err)
+	}
+	return newBlacklistStore(db)
+}
+
+func TestSignoutRevokesTokenImmediately(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer func() {
+		os.Chdir(wd)
+		keyStoreOnce = sync.Once{}
+		keyStore = nil
+	}()
+	keyStoreOnce = sync.Once{}
+	keyStore = nil
+
+	bl := newTestBlacklistStore(t)
+	setBlacklistStore(bl)
+	defer setBlacklistStore(nil)
+
+	os.Setenv("TODO_PASSWORD", "1234")
+	defer os.Unsetenv("TODO_PASSWORD")
+
+	token, err := makeJWT("1234")
+	if err != nil {
+		t.Fatalf("makeJWT вернул ошибку: %v", err)
+	}
+	if !validateJWT(token, "1234") {
+		t.Fatal("токен должен быть валиден до signout")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/signout", nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	rr := httptest.NewRecorder()
+	handleSignout(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ожидали 200 от handleSignout, получили %d: %s", rr.Code, rr.Body.String())
+	}
+	if validateJWT(token, "1234") {
+		t.Error("токен должен стать невалидным сразу после signout")
+	}
+}
+
+func TestBlacklistSweepPrunesExpiredRows(t *testing.T) {
+	bl := newTestBlacklistStore(t)
+
+	now := time.Now()
+	if err := bl.Revoke("expired-jti", now.Add(-time.Hour).Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if err := bl.Revoke("still-valid-jti", now.Add(time.Hour).Unix()); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := bl.Sweep(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("ожидали удаление 1 просроченной записи, удалено %d", n)
+	}
+
+	var count int
+	if err := bl.db.QueryRow(`SELECT COUNT(*) FROM blacklist`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("ожидали 1 оставшуюся запись в blacklist, найдено %d", count)
+	}
+}
+
+func TestJTILRUCacheConcurrentAccess(t *testing.T) {
+	cache := newJTILRUCache(50)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			jti := "jti-concurrent"
+			cache.Add(jti)
+			_ = cache.Contains(jti)
+		}(i)
+	}
+	wg.Wait()
+
+	if !cache.Contains("jti-concurrent") {
+		t.Error("ожидали, что jti останется в кэше после конкурентных вставок")
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Date — дата задачи (без времени суток), хранится и сериализуется в
+// каноническом формате DateFormat ("20060102"). Отдельный тип вместо
+// голой string устраняет целый класс багов парсинга/форматирования
+// (например, было легко случайно сравнить несовпадающие форматы или
+// забыть отформатировать перед записью в БД) и позволяет задаче
+// считывать несколько представлений, которые database/sql у SQLite
+// может вернуть для одного и того же столбца.
+type Date time.Time
+
+// ParseDate разбирает строку даты (в любом из форматов ParseTaskDate) в
+// Date. Пустая строка — нулевое значение Date (см. IsZero).
+func ParseDate(s string) (Date, error) {
+	if s == "" {
+		return Date{}, nil
+	}
+	t, err := ParseTaskDate(s)
+	if err != nil {
+		return Date{}, err
+	}
+	return Date(t), nil
+}
+
+// IsZero — true для нулевого значения Date (аналог time.Time.IsZero).
+func (d Date) IsZero() bool {
+	return time.Time(d).IsZero()
+}
+
+// String форматирует дату в канонический DateFormat.
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return time.Time(d).Format(DateFormat)
+}
+
+// Time отдаёт дату как time.Time — нужен местам, которые делают
+// арифметику над датами (NextDate и соседние функции в repeat.go/rrule.go).
+func (d Date) Time() time.Time {
+	return time.Time(d)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseDate(s)
+	if err != nil {
+		return fmt.Errorf("date: %w", err)
+	}
+	*d = parsed
+	return nil
+}
+
+// Value — IsZero-aware Valuer: нулевая дата хранится как NULL, а не как
+// "0001-01-01".
+func (d Date) Value() (driver.Value, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	return d.String(), nil
+}
+
+// Scan принимает все представления, которые database/sql может вернуть
+// для столбца даты: time.Time (некоторые драйверы конвертируют сами),
+// []byte/string в каноническом DateFormat, и — для SQLite, который
+// хранит значения как TEXT и при определённых запросах отдаёт их в виде
+// Go-строкового представления time.Time — ту же строку с хвостом вида
+// " 00:00:00 +0000 UTC".
+func (d *Date) Scan(value any) error {
+	if value == nil {
+		*d = Date{}
+		return nil
+	}
+	switch v := value.(type) {
+	case time.Time:
+		*d = Date(v)
+		return nil
+	case []byte:
+		return d.scanString(string(v))
+	case string:
+		return d.scanString(v)
+	default:
+		return fmt.Errorf("date: неподдерживаемый тип Scan: %T", value)
+	}
+}
+
+func (d *Date) scanString(s string) error {
+	if s == "" {
+		*d = Date{}
+		return nil
+	}
+	if idx := strings.Index(s, " "); idx > 0 && len(s) > len(DateFormat) {
+		// "2026-01-15 00:00:00 +0000 UTC" => берём только дату.
+		s = s[:idx]
+	}
+	parsed, err := ParseDate(s)
+	if err != nil {
+		return fmt.Errorf("date: не удалось разобрать %q: %w", s, err)
+	}
+	*d = parsed
+	return nil
+}
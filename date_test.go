@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDateJSONRoundTrip(t *testing.T) {
+	d, err := ParseDate("20260726")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"20260726"` {
+		t.Errorf("MarshalJSON = %s, ожидали \"20260726\"", data)
+	}
+
+	var roundtripped Date
+	if err := json.Unmarshal(data, &roundtripped); err != nil {
+		t.Fatal(err)
+	}
+	if roundtripped.String() != "20260726" {
+		t.Errorf("после round-trip получили %s, ожидали 20260726", roundtripped.String())
+	}
+}
+
+func TestDateZeroValue(t *testing.T) {
+	var d Date
+	if !d.IsZero() {
+		t.Error("нулевое значение Date должно быть IsZero")
+	}
+	val, err := d.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val != nil {
+		t.Errorf("Value() для нулевой даты = %v, ожидали nil", val)
+	}
+}
+
+func TestDateScanVariants(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{"canonical string", "20260726"},
+		{"canonical bytes", []byte("20260726")},
+		{"sqlite timestamp string", "2026-07-26 00:00:00 +0000 UTC"},
+	}
+
+	for _, tt := range tests {
+		var d Date
+		if err := d.Scan(tt.value); err != nil {
+			t.Errorf("%s: Scan вернул ошибку: %v", tt.name, err)
+			continue
+		}
+		if d.String() != "20260726" {
+			t.Errorf("%s: Scan => %s, ожидали 20260726", tt.name, d.String())
+		}
+	}
+
+	var zero Date
+	if err := zero.Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !zero.IsZero() {
+		t.Error("Scan(nil) должен дать нулевую дату")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// dateLayouts — форматы, которые понимает ParseTaskDate, в порядке
+// попытки разбора. DateFormat стоит первым, так как это основной формат,
+// в котором хранятся даты задач.
+var dateLayouts = []string{
+	DateFormat,
+	"2006-01-02",
+	time.RFC3339,
+	time.RFC3339Nano,
+	"02.01.2006",
+	"01/02/2006",
+}
+
+// ParseTaskDate разбирает строку даты в одном из нескольких
+// поддерживаемых форматов (основной "20060102", ISO 8601, RFC3339,
+// "02.01.2006", "01/02/2006", а также Unix-время в секундах или
+// миллисекундах, если строка целиком состоит из 10 или 13 цифр) и
+// возвращает её как time.Time. Раньше handleNextDate понимал только
+// DateFormat и на любой другой ввод молча отдавал пустой ответ — это
+// мешало клиентам API, которые хранят даты в своём формате.
+func ParseTaskDate(s string) (time.Time, error) {
+	if isAllDigits(s) {
+		switch len(s) {
+		case 10:
+			if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return time.Unix(sec, 0).UTC(), nil
+			}
+		case 13:
+			if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return time.UnixMilli(ms).UTC(), nil
+			}
+		}
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("не удалось распознать дату: %q", s)
+}
+
+func isAllDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTaskDateFormats(t *testing.T) {
+	want := time.Date(2026, 7, 26, 0, 0, 0, 0, time.UTC)
+	tests := []string{
+		"20260726",
+		"2026-07-26",
+		"26.07.2026",
+		"07/26/2026",
+		"2026-07-26T00:00:00Z",
+	}
+	for _, s := range tests {
+		got, err := ParseTaskDate(s)
+		if err != nil {
+			t.Errorf("ParseTaskDate(%q) вернул ошибку: %v", s, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("ParseTaskDate(%q) = %v, ожидали %v", s, got, want)
+		}
+	}
+}
+
+func TestParseTaskDateUnixTimestamps(t *testing.T) {
+	want := time.Unix(1785024000, 0).UTC() // 2026-07-26T00:00:00Z (ровно 10 цифр)
+	got, err := ParseTaskDate("1785024000")
+	if err != nil {
+		t.Fatalf("ParseTaskDate вернул ошибку: %v", err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("ParseTaskDate(seconds) = %v, ожидали %v", got, want)
+	}
+
+	gotMs, err := ParseTaskDate("1785024000000")
+	if err != nil {
+		t.Fatalf("ParseTaskDate вернул ошибку: %v", err)
+	}
+	if !gotMs.Equal(want) {
+		t.Errorf("ParseTaskDate(milliseconds) = %v, ожидали %v", gotMs, want)
+	}
+}
+
+func TestParseTaskDateRejectsGarbage(t *testing.T) {
+	if _, err := ParseTaskDate("not a date"); err == nil {
+		t.Error("ожидали ошибку для нераспознаваемой строки")
+	}
+}
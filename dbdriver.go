@@ -0,0 +1,52 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
+
+// Поддерживаемые значения TODO_DBDRIVER. По умолчанию (переменная не
+// задана) используется dbDriverSQLite — поведение не меняется для всех,
+// кто уже запускает сервис как раньше, с scheduler.db рядом с бинарником.
+const (
+	dbDriverSQLite   = "sqlite3"
+	dbDriverPostgres = "postgres"
+	dbDriverMySQL    = "mysql"
+)
+
+// openConfiguredDB открывает БД согласно TODO_DBDRIVER/TODO_DSN. Если обе
+// переменные не заданы, сохраняется прежнее поведение: SQLite-файл
+// scheduler.db в рабочем каталоге. Возвращает также имя драйвера — оно
+// нужно TaskRepository (bind, repository.go), чтобы знать, какой
+// синтаксис плейсхолдеров использовать.
+func openConfiguredDB() (*sql.DB, string, error) {
+	driver := os.Getenv("TODO_DBDRIVER")
+	if driver == "" {
+		driver = dbDriverSQLite
+	}
+
+	dsn := os.Getenv("TODO_DSN")
+	if dsn == "" {
+		if driver != dbDriverSQLite {
+			return nil, "", fmt.Errorf("TODO_DSN обязателен для TODO_DBDRIVER=%s", driver)
+		}
+		dsn = filepath.Join(".", "scheduler.db")
+	}
+
+	switch driver {
+	case dbDriverSQLite, dbDriverPostgres, dbDriverMySQL:
+	default:
+		return nil, "", fmt.Errorf("неизвестный TODO_DBDRIVER=%q (ожидали sqlite3, postgres или mysql)", driver)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, "", err
+	}
+	return db, driver, nil
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// icalHostSuffix используется как стабильный "домен" в UID задач — сам
+// сервер не публикует DNS-имя, поэтому берём фиксированную строку, как и
+// jwtIssuer для токенов.
+const icalHostSuffix = "todo-scheduler.local"
+
+// taskUID — стабильный идентификатор события календаря для задачи:
+// привязан к ID задачи, так что повторный экспорт и CalDAV-запись на ту
+// же задачу всегда используют один и тот же UID.
+func taskUID(taskID int) string {
+	return fmt.Sprintf("task-%d@%s", taskID, icalHostSuffix)
+}
+
+// isoWeekdayRRuleCodes переводит ISO-день недели (Mon=1..Sun=7, как в
+// weekRule/everyWeeksRule из repeat.go) в двухбуквенный код BYDAY из
+// RFC 5545.
+var isoWeekdayRRuleCodes = [8]string{"", "MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+func byDayList(weekdays map[int]bool) string {
+	var codes []string
+	for d := 1; d <= 7; d++ {
+		if weekdays[d] {
+			codes = append(codes, isoWeekdayRRuleCodes[d])
+		}
+	}
+	return strings.Join(codes, ",")
+}
+
+// monthRuleRRuleBody переводит monthRule (repeat.go) в тело RRULE.
+// Отрицательные дни (-1/-2 — последний/предпоследний день месяца) в
+// BYMONTHDAY означают ровно то же самое, что и в нашей грамматике, так
+// что переносятся как есть.
+func monthRuleRRuleBody(rule monthRule) string {
+	dayStrs := make([]string, len(rule.days))
+	for i, d := range rule.days {
+		dayStrs[i] = strconv.Itoa(d)
+	}
+	body := fmt.Sprintf("FREQ=MONTHLY;BYMONTHDAY=%s", strings.Join(dayStrs, ","))
+	if rule.months != nil {
+		var months []string
+		for m := 1; m <= 12; m++ {
+			if rule.months[m] {
+				months = append(months, strconv.Itoa(m))
+			}
+		}
+		body += ";BYMONTH=" + strings.Join(months, ",")
+	}
+	return body
+}
+
+// repeatToRRule переводит правило повторения NextDate — RRULE (отдаётся
+// как есть), компактную грамматику d/y/w/m/every и её необязательный
+// терминатор until/count (см. splitRepeatTerminator в repeat.go) — в
+// RFC 5545 RRULE для экспорта в iCalendar.
+func repeatToRRule(repeat string) (rrule string, ok bool) {
+	if isRRule(repeat) {
+		if !strings.HasPrefix(repeat, "RRULE:") {
+			repeat = "RRULE:" + repeat
+		}
+		return repeat, true
+	}
+
+	base, term, err := splitRepeatTerminator(repeat)
+	if err != nil {
+		return "", false
+	}
+
+	var body string
+	switch {
+	case strings.HasPrefix(base, "d "):
+		days := strings.TrimSpace(strings.TrimPrefix(base, "d "))
+		if days == "1" {
+			body = "FREQ=DAILY"
+		} else {
+			body = fmt.Sprintf("FREQ=DAILY;INTERVAL=%s", days)
+		}
+	case base == "y":
+		body = "FREQ=YEARLY"
+	case strings.HasPrefix(base, "w "):
+		rule, err := parseWeekRule(base)
+		if err != nil {
+			return "", false
+		}
+		body = fmt.Sprintf("FREQ=WEEKLY;BYDAY=%s", byDayList(rule.weekdays))
+	case strings.HasPrefix(base, "m "):
+		rule, err := parseMonthRule(base)
+		if err != nil {
+			return "", false
+		}
+		body = monthRuleRRuleBody(rule)
+	case strings.HasPrefix(base, "every "):
+		rule, err := parseEveryWeeksRule(base)
+		if err != nil {
+			return "", false
+		}
+		body = fmt.Sprintf("FREQ=WEEKLY;INTERVAL=%d;BYDAY=%s", rule.interval, byDayList(rule.weekdays))
+	default:
+		return "", false
+	}
+
+	if !term.until.IsZero() {
+		body += ";UNTIL=" + term.until.Format(DateFormat)
+	}
+	if term.count > 0 {
+		body += fmt.Sprintf(";COUNT=%d", term.count)
+	}
+
+	return "RRULE:" + body, true
+}
+
+// icalEscape экранирует спецсимволы RFC 5545 (запятую, точку с запятой,
+// обратный слэш и перенос строки) в текстовых полях VTODO.
+func icalEscape(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\n", `\n`,
+	)
+	return r.Replace(s)
+}
+
+// writeICalendar пишет задачи как VCALENDAR с одним VTODO на каждую —
+// VTODO, а не VEVENT, потому что задачи планировщика ближе к делам со
+// сроком выполнения, чем к событиям с длительностью.
+func writeICalendar(w io.Writer, tasks []Task) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, "BEGIN:VCALENDAR\r\n")
+	fmt.Fprint(bw, "VERSION:2.0\r\n")
+	fmt.Fprint(bw, "PRODID:-//todo-scheduler//ical export//RU\r\n")
+
+	for _, t := range tasks {
+		fmt.Fprint(bw, "BEGIN:VTODO\r\n")
+		fmt.Fprintf(bw, "UID:%s\r\n", taskUID(t.ID))
+		fmt.Fprintf(bw, "DTSTART;VALUE=DATE:%s\r\n", t.Date)
+		fmt.Fprintf(bw, "SUMMARY:%s\r\n", icalEscape(t.Title))
+		if t.Comment != "" {
+			fmt.Fprintf(bw, "DESCRIPTION:%s\r\n", icalEscape(t.Comment))
+		}
+		if rrule, ok := repeatToRRule(t.Repeat); ok {
+			fmt.Fprintf(bw, "%s\r\n", rrule)
+		}
+		fmt.Fprint(bw, "END:VTODO\r\n")
+	}
+
+	fmt.Fprint(bw, "END:VCALENDAR\r\n")
+	return bw.Flush()
+}
+
+// authenticateICSRequest проверяет токен из query-параметра "token"
+// (календарные клиенты вроде Thunderbird/Apple Calendar/DAVx5 обычно не
+// умеют слать cookie, но подставляют URL с токеном целиком), либо, если
+// он почему-то пришёл как cookie (например, для ручной проверки в
+// браузере), читает его оттуда. Возвращает userID/scoped — как
+// scopedUserID (users.go), но для запроса без context.Context,
+// заполняемого auth().
+func authenticateICSRequest(r *http.Request) (userID int64, scoped bool, err error) {
+	passEnv := os.Getenv("TODO_PASSWORD")
+	if passEnv == "" {
+		return 0, false, nil
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		if c, cookieErr := r.Cookie("token"); cookieErr == nil {
+			token = c.Value
+		}
+	}
+	if token == "" {
+		return 0, false, errors.New("отсутствует токен")
+	}
+
+	payload, err := parseAndVerifyJWTAt(token, time.Now())
+	if err != nil {
+		return 0, false, err
+	}
+	if payload.Sub == "" && payload.PwdHash != makePasswordHash(passEnv) {
+		return 0, false, errors.New("невалидный токен")
+	}
+	if id, convErr := strconv.ParseInt(payload.Sub, 10, 64); convErr == nil && id > 0 {
+		return id, true, nil
+	}
+	return 0, false, nil
+}
+
+// handleTasksICS отдаёт GET /api/tasks.ics — iCalendar-фид задач текущего
+// пользователя (или всех задач, если аутентификация не настроена).
+func handleTasksICS(repo TaskRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, scoped, err := authenticateICSRequest(r)
+		if err != nil {
+			http.Error(w, "Authentification required", http.StatusUnauthorized)
+			return
+		}
+
+		tasks, err := repo.ListAll(userID, scoped)
+		if err != nil {
+			http.Error(w, "Ошибка чтения задач из БД", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="tasks.ics"`)
+		if err := writeICalendar(w, tasks); err != nil {
+			http.Error(w, "Ошибка генерации iCalendar", http.StatusInternalServerError)
+		}
+	}
+}
+
+// handleCalDAVComplete — минимальный "CalDAV": полноценный PROPFIND/REPORT
+// и коллекции здесь не реализованы, но основной сценарий двустороннего
+// использования — "отметить VTODO выполненной из календарного клиента" —
+// поддержан: PUT с телом, где есть "STATUS:COMPLETED", проходит тот же
+// путь, что и handleDoneTask (повторяющаяся задача — сдвигаем дату через
+// NextDate, одноразовая — удаляем).
+func handleCalDAVComplete(repo TaskRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		userID, scoped, err := authenticateICSRequest(r)
+		if err != nil {
+			http.Error(w, "Authentification required", http.StatusUnauthorized)
+			return
+		}
+
+		idStr := r.URL.Query().Get("id")
+		taskID, err := strconv.Atoi(idStr)
+		if err != nil || taskID <= 0 {
+			http.Error(w, "Некорректный ID задачи", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Ошибка чтения тела запроса", http.StatusBadRequest)
+			return
+		}
+		if !strings.Contains(string(body), "STATUS:COMPLETED") {
+			// Ничего менять не просили — обычный PUT-апдейт VTODO, помимо
+			// отметки выполнения, здесь не поддерживается.
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+
+		t, err := repo.Get(taskID, userID, scoped)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Задача не найдена", http.StatusNotFound)
+			} else {
+				http.Error(w, "Ошибка чтения задачи из БД", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		if t.Repeat == "" {
+			if _, err := repo.Delete(taskID, userID, scoped); err != nil {
+				http.Error(w, "Ошибка удаления задачи из БД", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		newDate, err := NextDate(time.Now(), t.Date.String(), t.Repeat)
+		if err != nil {
+			if errors.Is(err, ErrSeriesEnded) {
+				if _, err := repo.Delete(taskID, userID, scoped); err != nil {
+					http.Error(w, "Ошибка удаления задачи из БД", http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			http.Error(w, "Ошибка вычисления даты повторения", http.StatusBadRequest)
+			return
+		}
+
+		t.Date, err = ParseDate(newDate)
+		if err != nil {
+			http.Error(w, "Ошибка вычисления даты повторения", http.StatusInternalServerError)
+			return
+		}
+		if _, err := repo.Update(t, userID, scoped); err != nil {
+			http.Error(w, "Ошибка обновления задачи в БД", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func setupICSTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := createSchedulerTable(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateSchedulerUserID(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestRepeatToRRule(t *testing.T) {
+	cases := []struct {
+		repeat string
+		rrule  string
+		ok     bool
+	}{
+		{"d 1", "RRULE:FREQ=DAILY", true},
+		{"d 7", "RRULE:FREQ=DAILY;INTERVAL=7", true},
+		{"y", "RRULE:FREQ=YEARLY", true},
+		{"", "", false},
+		{"w 1", "RRULE:FREQ=WEEKLY;BYDAY=MO", true},
+		{"w 1,3,5", "RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR", true},
+		{"w 8", "", false}, // недопустимый день недели
+		{"m 15", "RRULE:FREQ=MONTHLY;BYMONTHDAY=15", true},
+		{"m -1", "RRULE:FREQ=MONTHLY;BYMONTHDAY=-1", true},
+		{"m 1 3,6", "RRULE:FREQ=MONTHLY;BYMONTHDAY=1;BYMONTH=3,6", true},
+		{"every 2 weeks on 1,3", "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE", true},
+		{"RRULE:FREQ=DAILY;INTERVAL=3", "RRULE:FREQ=DAILY;INTERVAL=3", true},
+		{"FREQ=WEEKLY;BYDAY=TU", "RRULE:FREQ=WEEKLY;BYDAY=TU", true},
+		{"d 7 until 20261231", "RRULE:FREQ=DAILY;INTERVAL=7;UNTIL=20261231", true},
+		{"w 1,3,5 count 12", "RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=12", true},
+	}
+	for _, c := range cases {
+		rrule, ok := repeatToRRule(c.repeat)
+		if ok != c.ok || rrule != c.rrule {
+			t.Errorf("repeatToRRule(%q) = (%q, %v), ожидали (%q, %v)", c.repeat, rrule, ok, c.rrule, c.ok)
+		}
+	}
+}
+
+func TestHandleTasksICSWithoutAuth(t *testing.T) {
+	db := setupICSTestDB(t)
+	if _, err := db.Exec(`INSERT INTO scheduler (title, date, comment, repeat) VALUES (?, ?, ?, ?)`,
+		"Встреча", "20260101", "", "y"); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks.ics", nil)
+	rr := httptest.NewRecorder()
+	handleTasksICS(NewTaskRepository(db, dbDriverSQLite)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", rr.Code, rr.Body.String())
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "BEGIN:VCALENDAR") || !strings.Contains(body, "END:VCALENDAR") {
+		t.Error("ответ должен быть обёрнут в VCALENDAR")
+	}
+	if !strings.Contains(body, "BEGIN:VTODO") {
+		t.Error("ожидали хотя бы один VTODO")
+	}
+	if !strings.Contains(body, "DTSTART;VALUE=DATE:20260101") {
+		t.Error("ожидали DTSTART со значением даты задачи")
+	}
+	if !strings.Contains(body, "RRULE:FREQ=YEARLY") {
+		t.Error("ожидали RRULE для годового повторения")
+	}
+	if !strings.Contains(body, "UID:task-1@"+icalHostSuffix) {
+		t.Error("ожидали стабильный UID, привязанный к ID задачи")
+	}
+}
+
+func TestHandleCalDAVCompleteOneShotDeletes(t *testing.T) {
+	db := setupICSTestDB(t)
+	res, err := db.Exec(`INSERT INTO scheduler (title, date, comment, repeat) VALUES (?, ?, ?, ?)`,
+		"Разовая задача", "20260101", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+
+	req := httptest.NewRequest(http.MethodPut, "/api/caldav/tasks?id="+strconv.FormatInt(id, 10),
+		strings.NewReader("BEGIN:VTODO\r\nSTATUS:COMPLETED\r\nEND:VTODO\r\n"))
+	rr := httptest.NewRecorder()
+	handleCalDAVComplete(NewTaskRepository(db, dbDriverSQLite)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("ожидали 204, получили %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM scheduler WHERE id = ?`, id).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Error("одноразовая задача должна быть удалена после отметки выполненной")
+	}
+}
+
+func TestHandleCalDAVCompleteRecurringAdvancesDate(t *testing.T) {
+	db := setupICSTestDB(t)
+	res, err := db.Exec(`INSERT INTO scheduler (title, date, comment, repeat) VALUES (?, ?, ?, ?)`,
+		"Повторяющаяся задача", "20260101", "", "d 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+
+	req := httptest.NewRequest(http.MethodPut, "/api/caldav/tasks?id="+strconv.FormatInt(id, 10),
+		strings.NewReader("BEGIN:VTODO\r\nSTATUS:COMPLETED\r\nEND:VTODO\r\n"))
+	rr := httptest.NewRecorder()
+	handleCalDAVComplete(NewTaskRepository(db, dbDriverSQLite)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("ожидали 204, получили %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var date string
+	if err := db.QueryRow(`SELECT date FROM scheduler WHERE id = ?`, id).Scan(&date); err != nil {
+		t.Fatal(err)
+	}
+	if date == "20260101" {
+		t.Error("повторяющаяся задача должна получить новую дату, а не остаться прежней")
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+)
+
+// handleJWKS отдаёт /.well-known/jwks.json — публичные ключи, которыми
+// подписаны наши токены (jwt.go), чтобы сторонние сервисы могли
+// проверять их подлинность, не имея приватного ключа.
+func handleJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	var set jwkSet
+	for _, k := range getKeyStore().publicKeys() {
+		eBytes := make([]byte, 8)
+		binary.BigEndian.PutUint64(eBytes, uint64(k.Pub.E))
+		// убираем ведущие нулевые байты — JWK ожидает минимальную запись E
+		i := 0
+		for i < len(eBytes)-1 && eBytes[i] == 0 {
+			i++
+		}
+		set.Keys = append(set.Keys, jwk{
+			Kty: "RSA",
+			Kid: k.Kid,
+			N:   base64.RawURLEncoding.EncodeToString(k.Pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(eBytes[i:]),
+		})
+	}
+
+	json.NewEncoder(w).Encode(set)
+}
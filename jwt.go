@@ -0,0 +1,416 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Время жизни токена и порог, после которого auth() перевыпускает cookie
+// с новым сроком действия, чтобы активный пользователь не был разлогинен
+// посередине работы.
+const (
+	jwtTTL             = 15 * time.Minute
+	jwtRefreshWindow   = 5 * time.Minute
+	defaultKeyRotation = 24 * time.Hour
+	jwtIssuer          = "todo-scheduler"
+	jwtAudience        = "todo-scheduler-api"
+	keysFileName       = "jwtkeys.json"
+	rsaKeyBits         = 2048
+)
+
+// jwtKey — один ключ подписи RS256 с идентификатором kid, по которому его
+// можно найти в header'е токена при верификации. Подписываем и проверяем
+// асимметрично, чтобы публичный ключ можно было отдать сторонним
+// сервисам через /.well-known/jwks.json, не раскрывая приватный ключ.
+type jwtKey struct {
+	Kid        string
+	PrivateKey *rsa.PrivateKey
+	CreatedAt  time.Time
+}
+
+// jwtKeyJSON — то, в каком виде jwtKey хранится на диске: приватный ключ
+// в формате PEM, а не "голый" rsa.PrivateKey, чтобы файл ключей оставался
+// человекочитаемым и переносимым.
+type jwtKeyJSON struct {
+	Kid           string    `json:"kid"`
+	PrivateKeyPEM string    `json:"private_key_pem"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (k jwtKey) MarshalJSON() ([]byte, error) {
+	der := x509.MarshalPKCS1PrivateKey(k.PrivateKey)
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: der})
+	return json.Marshal(jwtKeyJSON{Kid: k.Kid, PrivateKeyPEM: string(pemBytes), CreatedAt: k.CreatedAt})
+}
+
+func (k *jwtKey) UnmarshalJSON(data []byte) error {
+	var aux jwtKeyJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	block, _ := pem.Decode([]byte(aux.PrivateKeyPEM))
+	if block == nil {
+		return errors.New("некорректный PEM-блок приватного ключа")
+	}
+	priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+	k.Kid = aux.Kid
+	k.PrivateKey = priv
+	k.CreatedAt = aux.CreatedAt
+	return nil
+}
+
+// jwtKeyStore хранит текущий и предыдущий ключи подписи, чтобы токены,
+// выпущенные до ротации, продолжали проходить проверку в течение
+// переходного периода.
+type jwtKeyStore struct {
+	mu       sync.Mutex
+	path     string
+	Current  *jwtKey `json:"current"`
+	Previous *jwtKey `json:"previous,omitempty"`
+}
+
+var (
+	keyStoreOnce sync.Once
+	keyStore     *jwtKeyStore
+)
+
+func getKeyStore() *jwtKeyStore {
+	return getKeyStoreAt(time.Now())
+}
+
+// getKeyStoreAt — как getKeyStore, но с явным now для самого первого
+// ("холодного") создания ключа, если файла ключей ещё нет. Нужен, чтобы
+// signJWTPayloadAt/parseAndVerifyJWTAt могли породить самый первый ключ с
+// CreatedAt, согласованным с их собственным now, а не реальным временем
+// процесса — иначе тесты с фиктивным now никогда не увидят ротацию.
+func getKeyStoreAt(now time.Time) *jwtKeyStore {
+	keyStoreOnce.Do(func() {
+		ks, err := loadOrInitKeyStoreAt(keysFileName, now)
+		if err != nil {
+			// Без ключа подписи сервер не может выпускать токены — это
+			// ситуация уровня log.Fatal в остальном коде, но здесь нам
+			// достаточно держать ключ в памяти на время процесса.
+			ks = &jwtKeyStore{path: keysFileName, Current: mustGenerateKeyAt(now)}
+		}
+		keyStore = ks
+	})
+	return keyStore
+}
+
+func mustGenerateKeyAt(now time.Time) *jwtKey {
+	k, err := generateKeyAt(now)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+// generateKeyAt создаёт новый ключ подписи с CreatedAt = now, а не реальным
+// временем процесса — это то, что позволяет тестам (и signingKeyAt при
+// ротации) полностью контролировать момент, с которого начинается отсчёт
+// KEY_ROTATION_INTERVAL для только что созданного ключа.
+func generateKeyAt(now time.Time) (*jwtKey, error) {
+	kidBytes := make([]byte, 8)
+	if _, err := rand.Read(kidBytes); err != nil {
+		return nil, err
+	}
+	priv, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		return nil, err
+	}
+	return &jwtKey{
+		Kid:        base64.RawURLEncoding.EncodeToString(kidBytes),
+		PrivateKey: priv,
+		CreatedAt:  now,
+	}, nil
+}
+
+func loadOrInitKeyStore(path string) (*jwtKeyStore, error) {
+	return loadOrInitKeyStoreAt(path, time.Now())
+}
+
+// loadOrInitKeyStoreAt — как loadOrInitKeyStore, но с явным now для
+// "холодного" создания ключа (когда файла ключей ещё нет), чтобы тесты
+// могли подставлять фиксированное время вместо time.Now().
+func loadOrInitKeyStoreAt(path string, now time.Time) (*jwtKeyStore, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err := generateKeyAt(now)
+		if err != nil {
+			return nil, err
+		}
+		ks := &jwtKeyStore{path: path, Current: key}
+		if err := ks.persist(); err != nil {
+			return nil, err
+		}
+		return ks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ks jwtKeyStore
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return nil, err
+	}
+	ks.path = path
+	return &ks, nil
+}
+
+func (ks *jwtKeyStore) persist() error {
+	data, err := json.MarshalIndent(ks, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ks.path, data, 0600)
+}
+
+func rotationInterval() time.Duration {
+	raw := os.Getenv("KEY_ROTATION_INTERVAL")
+	if raw == "" {
+		return defaultKeyRotation
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return defaultKeyRotation
+	}
+	return d
+}
+
+// signingKeyAt возвращает ключ, которым нужно подписывать новые токены в
+// момент now, проводя ротацию (текущий -> предыдущий, новый -> текущий),
+// если прошёл KEY_ROTATION_INTERVAL с момента создания текущего ключа.
+func (ks *jwtKeyStore) signingKeyAt(now time.Time) (*jwtKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.Current == nil {
+		k, err := generateKeyAt(now)
+		if err != nil {
+			return nil, err
+		}
+		ks.Current = k
+		if err := ks.persist(); err != nil {
+			return nil, err
+		}
+		return ks.Current, nil
+	}
+
+	if now.Sub(ks.Current.CreatedAt) >= rotationInterval() {
+		next, err := generateKeyAt(now)
+		if err != nil {
+			return nil, err
+		}
+		ks.Previous = ks.Current
+		ks.Current = next
+		if err := ks.persist(); err != nil {
+			return nil, err
+		}
+	}
+	return ks.Current, nil
+}
+
+// keyByKid ищет ключ (текущий или предыдущий) с данным kid — это то, что
+// позволяет токенам, выпущенным до ротации, оставаться валидными ещё
+// один цикл ротации.
+func (ks *jwtKeyStore) keyByKid(kid string) (*jwtKey, bool) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.Current != nil && ks.Current.Kid == kid {
+		return ks.Current, true
+	}
+	if ks.Previous != nil && ks.Previous.Kid == kid {
+		return ks.Previous, true
+	}
+	return nil, false
+}
+
+// signingPublicKey — kid вместе с публичной частью ключа подписи, без
+// приватного компонента — то, что можно безопасно отдать наружу.
+type signingPublicKey struct {
+	Kid string
+	Pub *rsa.PublicKey
+}
+
+// publicKeys возвращает kid/публичный ключ для всех ключей, ещё годных к
+// проверке (текущего и, если есть, предыдущего) — используется
+// /.well-known/jwks.json (jwks.go), чтобы сторонние сервисы могли
+// проверять наши токены, не видя приватный ключ.
+func (ks *jwtKeyStore) publicKeys() []signingPublicKey {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	var out []signingPublicKey
+	if ks.Current != nil {
+		out = append(out, signingPublicKey{ks.Current.Kid, &ks.Current.PrivateKey.PublicKey})
+	}
+	if ks.Previous != nil {
+		out = append(out, signingPublicKey{ks.Previous.Kid, &ks.Previous.PrivateKey.PublicKey})
+	}
+	return out
+}
+
+// jwtHeader — заголовок токена; Kid указывает, каким ключом из keyStore
+// токен подписан.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+func randomJTI() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// signJWTPayloadAt подписывает payload ключом RS256, актуальным на момент
+// now, и проставляет стандартные claims (iss/aud/iat/exp/nbf/jti), если
+// они ещё не заданы вызывающим кодом.
+func signJWTPayloadAt(p jwtPayload, now time.Time) (string, error) {
+	key, err := getKeyStoreAt(now).signingKeyAt(now)
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения ключа подписи: %w", err)
+	}
+
+	if p.Iss == "" {
+		p.Iss = jwtIssuer
+	}
+	if p.Aud == "" {
+		p.Aud = jwtAudience
+	}
+	if p.Iat == 0 {
+		p.Iat = now.Unix()
+	}
+	if p.Exp == 0 {
+		p.Exp = now.Add(jwtTTL).Unix()
+	}
+	if p.Nbf == 0 {
+		p.Nbf = now.Unix()
+	}
+	if p.Jti == "" {
+		p.Jti = randomJTI()
+	}
+
+	headerBytes, err := json.Marshal(jwtHeader{Alg: "RS256", Typ: "JWT", Kid: key.Kid})
+	if err != nil {
+		return "", err
+	}
+	header := base64.RawURLEncoding.EncodeToString(headerBytes)
+
+	payBytes, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(payBytes)
+
+	digest := sha256.Sum256([]byte(header + "." + payload))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key.PrivateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("ошибка подписи токена: %w", err)
+	}
+	sign := base64.RawURLEncoding.EncodeToString(sig)
+
+	return header + "." + payload + "." + sign, nil
+}
+
+// ErrTokenExpired — отдельный признак просроченного (но иначе валидного)
+// токена, чтобы вызывающий код мог отличить его от подделки.
+var ErrTokenExpired = errors.New("токен просрочен")
+
+// parseAndVerifyJWTAt проверяет подпись токена (с учётом ротации ключей) и
+// возвращает его payload. now используется для проверки exp/nbf, что
+// позволяет тестам подставлять фиксированное время вместо time.Now().
+func parseAndVerifyJWTAt(token string, now time.Time) (jwtPayload, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return jwtPayload{}, errors.New("некорректный формат токена")
+	}
+	header, payload, sign := parts[0], parts[1], parts[2]
+
+	var h jwtHeader
+	headerBytes, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return jwtPayload{}, err
+	}
+	if err := json.Unmarshal(headerBytes, &h); err != nil {
+		return jwtPayload{}, err
+	}
+
+	key, ok := getKeyStoreAt(now).keyByKid(h.Kid)
+	if !ok {
+		return jwtPayload{}, errors.New("неизвестный kid — ключ отозван или токен подделан")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(sign)
+	if err != nil {
+		return jwtPayload{}, err
+	}
+	digest := sha256.Sum256([]byte(header + "." + payload))
+	if err := rsa.VerifyPKCS1v15(&key.PrivateKey.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		return jwtPayload{}, errors.New("неверная подпись токена")
+	}
+
+	payBytes, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return jwtPayload{}, err
+	}
+	var p jwtPayload
+	if err := json.Unmarshal(payBytes, &p); err != nil {
+		return jwtPayload{}, err
+	}
+
+	if p.Nbf != 0 && now.Unix() < p.Nbf {
+		return jwtPayload{}, errors.New("токен ещё не активен")
+	}
+	if p.Exp != 0 && now.Unix() > p.Exp {
+		return p, ErrTokenExpired
+	}
+	if bl := getBlacklistStore(); bl != nil && bl.IsRevoked(p.Jti) {
+		return jwtPayload{}, ErrTokenRevoked
+	}
+	return p, nil
+}
+
+// ErrTokenRevoked — токен технически ещё не истёк, но его jti есть в
+// blacklist (см. handleSignout в blacklist.go).
+var ErrTokenRevoked = errors.New("токен отозван")
+
+// jtiAndExpFromToken проверяет подпись токена (но не blacklist и не
+// истечение срока — signout должен уметь отозвать даже токен, у которого
+// вот-вот кончится exp) и возвращает его jti/exp, чтобы handleSignout мог
+// положить их в таблицу blacklist.
+func jtiAndExpFromToken(token string, now time.Time) (jti string, exp int64, err error) {
+	p, err := parseAndVerifySignatureOnly(token, now)
+	if err != nil {
+		return "", 0, err
+	}
+	return p.Jti, p.Exp, nil
+}
+
+// parseAndVerifySignatureOnly — как parseAndVerifyJWTAt, но не считает
+// истёкший exp ошибкой (и не проверяет blacklist) — используется там, где
+// нужно прочитать claims уже потенциально просроченного токена.
+func parseAndVerifySignatureOnly(token string, now time.Time) (jwtPayload, error) {
+	p, err := parseAndVerifyJWTAt(token, now)
+	if err != nil && !errors.Is(err, ErrTokenExpired) && !errors.Is(err, ErrTokenRevoked) {
+		return jwtPayload{}, err
+	}
+	return p, nil
+}
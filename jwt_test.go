@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestValidateJWTAtExpiry(t *testing.T) {
+	issued := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	token, err := signJWTPayloadAt(jwtPayload{PwdHash: makePasswordHash("1234")}, issued)
+	if err != nil {
+		t.Fatalf("signJWTPayloadAt вернул ошибку: %v", err)
+	}
+
+	if !validateJWTAt(token, "1234", issued.Add(time.Minute)) {
+		t.Error("токен должен быть валиден сразу после выдачи")
+	}
+
+	afterExpiry := issued.Add(jwtTTL + time.Minute)
+	if validateJWTAt(token, "1234", afterExpiry) {
+		t.Error("токен должен быть невалиден после истечения exp")
+	}
+}
+
+func TestKeyRotationKeepsOldTokenValidDuringWindow(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer func() {
+		os.Chdir(wd)
+		// Возвращаем синглтон в исходное состояние, чтобы остальные тесты
+		// не унаследовали keyStore, привязанный к удалённому temp-каталогу.
+		keyStoreOnce = sync.Once{}
+		keyStore = nil
+	}()
+
+	// Сбрасываем синглтон, чтобы тест не подхватил keyStore другого теста.
+	keyStoreOnce = sync.Once{}
+	keyStore = nil
+
+	os.Setenv("KEY_ROTATION_INTERVAL", "1m")
+	defer os.Unsetenv("KEY_ROTATION_INTERVAL")
+
+	issued := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	token, err := signJWTPayloadAt(jwtPayload{PwdHash: makePasswordHash("1234")}, issued)
+	if err != nil {
+		t.Fatalf("signJWTPayloadAt вернул ошибку: %v", err)
+	}
+
+	// Между выдачей токена и следующей проверкой прошёл KEY_ROTATION_INTERVAL
+	// (но не jwtTTL), поэтому ключ успел смениться — старый токен должен
+	// всё ещё проверяться старым ("previous") ключом.
+	afterRotation := issued.Add(2 * time.Minute)
+	if _, err := getKeyStore().signingKeyAt(afterRotation); err != nil {
+		t.Fatalf("signingKeyAt вернул ошибку: %v", err)
+	}
+
+	if !validateJWTAt(token, "1234", afterRotation) {
+		t.Error("токен, подписанный предыдущим ключом, должен оставаться валидным после ротации")
+	}
+}
+
+func TestParseAndVerifyRejectsTokenSignedWithRetiredKey(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer func() {
+		os.Chdir(wd)
+		keyStoreOnce = sync.Once{}
+		keyStore = nil
+	}()
+
+	keyStoreOnce = sync.Once{}
+	keyStore = nil
+
+	os.Setenv("KEY_ROTATION_INTERVAL", "1m")
+	defer os.Unsetenv("KEY_ROTATION_INTERVAL")
+
+	issued := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	token, err := signJWTPayloadAt(jwtPayload{PwdHash: makePasswordHash("1234")}, issued)
+	if err != nil {
+		t.Fatalf("signJWTPayloadAt вернул ошибку: %v", err)
+	}
+
+	// Две ротации подряд: ключ, которым подписан token, сначала становится
+	// "previous", а затем выбывает из keyStore совсем — токен больше не
+	// должен проходить проверку ни под каким видом.
+	afterFirstRotation := issued.Add(2 * time.Minute)
+	if _, err := getKeyStore().signingKeyAt(afterFirstRotation); err != nil {
+		t.Fatalf("signingKeyAt вернул ошибку: %v", err)
+	}
+	afterSecondRotation := afterFirstRotation.Add(2 * time.Minute)
+	if _, err := getKeyStore().signingKeyAt(afterSecondRotation); err != nil {
+		t.Fatalf("signingKeyAt вернул ошибку: %v", err)
+	}
+
+	if validateJWTAt(token, "1234", afterSecondRotation) {
+		t.Error("токен, подписанный уже отозванным ключом, не должен проходить проверку")
+	}
+	if _, err := parseAndVerifyJWTAt(token, afterSecondRotation); err == nil {
+		t.Error("parseAndVerifyJWTAt должен вернуть ошибку для токена с неизвестным kid")
+	}
+}
+
+// withTempKeyStore изолирует jwtkeys.json в отдельном временном каталоге
+// на время теста и сбрасывает синглтон keyStore до и после — тот же
+// приём, что и в TestKeyRotationKeepsOldTokenValidDuringWindow.
+func withTempKeyStore(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		keyStoreOnce = sync.Once{}
+		keyStore = nil
+	})
+	keyStoreOnce = sync.Once{}
+	keyStore = nil
+}
+
+func TestHandleJWKSPublishesCurrentKey(t *testing.T) {
+	withTempKeyStore(t)
+
+	// Инициализируем ключ подписи
+	if _, err := signJWTPayload(jwtPayload{PwdHash: makePasswordHash("1234")}); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	rr := httptest.NewRecorder()
+	handleJWKS(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d", rr.Code)
+	}
+
+	var set jwkSet
+	if err := json.Unmarshal(rr.Body.Bytes(), &set); err != nil {
+		t.Fatalf("ошибка разбора JWKS: %v", err)
+	}
+	if len(set.Keys) != 1 {
+		t.Fatalf("ожидали 1 ключ в JWKS, получили %d", len(set.Keys))
+	}
+	if set.Keys[0].Kty != "RSA" {
+		t.Errorf("ожидали kty=RSA, получили %q", set.Keys[0].Kty)
+	}
+	if set.Keys[0].Kid != getKeyStore().Current.Kid {
+		t.Error("kid в JWKS должен совпадать с kid текущего ключа подписи")
+	}
+
+	pub, err := jwkToPublicKey(set.Keys[0])
+	if err != nil {
+		t.Fatalf("jwkToPublicKey вернул ошибку: %v", err)
+	}
+	if pub.N.Cmp(getKeyStore().Current.PrivateKey.PublicKey.N) != 0 {
+		t.Error("модуль N в JWKS не совпадает с публичным ключом текущего ключа подписи")
+	}
+}
+
+func TestHandleRefreshIssuesNewTokenAndRevokesOld(t *testing.T) {
+	withTempKeyStore(t)
+
+	bl := newTestBlacklistStore(t)
+	setBlacklistStore(bl)
+	defer setBlacklistStore(nil)
+
+	token, err := signJWTPayload(jwtPayload{PwdHash: makePasswordHash("1234")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPayload, err := parseAndVerifyJWTAt(token, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	rr := httptest.NewRecorder()
+	handleRefresh(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp SigninResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Token == "" || resp.Token == token {
+		t.Error("handleRefresh должен вернуть новый, отличный от старого токен")
+	}
+	if !validateJWT(resp.Token, "1234") {
+		t.Error("новый токен должен быть валиден")
+	}
+	if !bl.IsRevoked(oldPayload.Jti) {
+		t.Error("старый jti должен быть отозван после refresh")
+	}
+}
@@ -1,17 +1,18 @@
 package main
 
 import (
-	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -19,8 +20,11 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// SigninRequest — модель для чтения JSON при POST /api/signin
+// SigninRequest — модель для чтения JSON при POST /api/signin. Login
+// пустой => вход по общему паролю (TODO_PASSWORD), как и раньше; Login
+// заполнен => вход под конкретным пользователем из таблицы users.
 type SigninRequest struct {
+	Login    string `json:"login,omitempty"`
 	Password string `json:"password"`
 }
 
@@ -35,65 +39,94 @@ func makeJWT(password string) (string, error) {
 	if password == "" {
 		return "", errors.New("пустой пароль")
 	}
-	// 1) Заголовок (header)
-	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
-
-	// 2) Полезная нагрузка (payload): здесь запишем хеш пароля
-	//    например, sha256 от (password + некий secret)
 	hash := makePasswordHash(password)
-	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"pwdhash":"%s"}`, hash)))
-
-	// 3) Подпись (signature): HMAC-SHA256(header+"."+payload, secretKey)
-	//    Для наглядности возьмём secretKey = "MySuperSecret"
-	secretKey := []byte("MySuperSecret")
-	h := hmac.New(sha256.New, secretKey)
-	data := header + "." + payload
-	h.Write([]byte(data))
-	sign := base64.RawURLEncoding.EncodeToString(h.Sum(nil))
-
-	// Собираем всё вместе: header.payload.signature
-	token := header + "." + payload + "." + sign
-	return token, nil
+	return signJWTPayload(jwtPayload{PwdHash: hash})
 }
 
-// validateJWT проверяет токен, что там &laquo;pwdhash&raquo; совпадает с актуальным паролем
+// validateJWT проверяет подпись, срок действия токена (с учётом ротации
+// ключей в jwt.go) и что pwdhash в payload совпадает с актуальным паролем.
 func validateJWT(token string, password string) bool {
-	// Разбиваем на 3 части
-	parts := strings.Split(token, ".")
-	if len(parts) != 3 {
-		return false
-	}
-	header, payload, sign := parts[0], parts[1], parts[2]
-
-	// 1) Проверим подпись
-	secretKey := []byte("MySuperSecret")
-	h := hmac.New(sha256.New, secretKey)
-	h.Write([]byte(header + "." + payload))
-	expectedSign := base64.RawURLEncoding.EncodeToString(h.Sum(nil))
-	if sign != expectedSign {
-		return false
-	}
+	return validateJWTAt(token, password, time.Now())
+}
 
-	// 2) Раскодируем payload
-	payBytes, err := base64.RawURLEncoding.DecodeString(payload)
+// validateJWTAt — то же самое, что validateJWT, но с явно заданным
+// текущим временем, чтобы тесты ротации ключей/истечения токена не
+// зависели от реальных часов.
+func validateJWTAt(token string, password string, now time.Time) bool {
+	p, err := parseAndVerifyJWTAt(token, now)
 	if err != nil {
 		return false
 	}
-	// Ожидаем что-то вида {"pwdhash":"..."}
-	type Payload struct {
-		PwdHash string `json:"pwdhash"`
-	}
-	var p Payload
-	if err := json.Unmarshal(payBytes, &p); err != nil {
-		return false
+	currentHash := makePasswordHash(password)
+	return p.PwdHash == currentHash
+}
+
+// jwtPayload — полезная нагрузка токена приложения. PwdHash используется
+// для обычного входа по общему паролю (начиная с перехода на RS256 в
+// jwt.go это больше не механизм защиты — подделать токен без приватного
+// ключа всё равно нельзя, — а лишь способ считать легаси-токен
+// недействительным, если TODO_PASSWORD сменили). Sub/Email заполняются,
+// когда токен выпущен через OIDC (см. oidc.go) или для пользователя из
+// таблицы users (Sub — его id). Iss/Aud/Iat/Exp/Nbf/Jti — обычные
+// JWT-claims, заполняются автоматически в signJWTPayloadAt (jwt.go).
+type jwtPayload struct {
+	PwdHash string `json:"pwdhash,omitempty"`
+	Sub     string `json:"sub,omitempty"`
+	Email   string `json:"email,omitempty"`
+	Role    string `json:"role,omitempty"`
+	Iss     string `json:"iss,omitempty"`
+	Aud     string `json:"aud,omitempty"`
+	Iat     int64  `json:"iat,omitempty"`
+	Exp     int64  `json:"exp,omitempty"`
+	Nbf     int64  `json:"nbf,omitempty"`
+	Jti     string `json:"jti,omitempty"`
+}
+
+// signJWTPayload подписывает payload текущим ключом ротации на момент
+// вызова. См. signJWTPayloadAt (jwt.go) для варианта с явным временем,
+// которым пользуются тесты.
+func signJWTPayload(p jwtPayload) (string, error) {
+	return signJWTPayloadAt(p, time.Now())
+}
+
+// oidcSubPrefix отделяет пространство идентификаторов внешних OIDC-sub от
+// пространства числовых user_id из таблицы users. Без префикса provider-
+// side sub мог бы случайно (или намеренно, если атакующий контролирует
+// настроенный issuer) совпасть со строковым представлением чьего-то
+// user_id — и auth() (main.go), который делает strconv.ParseInt(Sub, ...)
+// без разбора, кто выпустил токен, принял бы OIDC-сессию за сессию этого
+// локального пользователя со всеми его задачами.
+const oidcSubPrefix = "oidc:"
+
+// makeOIDCJWT выпускает токен приложения для пользователя, вошедшего
+// через внешнего OIDC-провайдера (см. handleOIDCCallback).
+func makeOIDCJWT(sub, email string) (string, error) {
+	if sub == "" {
+		return "", errors.New("пустой sub")
 	}
+	return signJWTPayload(jwtPayload{Sub: oidcSubPrefix + sub, Email: email})
+}
 
-	// 3) Проверяем, совпадает ли p.PwdHash с актуальной хэш-строкой
-	currentHash := makePasswordHash(password)
-	if p.PwdHash != currentHash {
-		return false
+// validateOIDCJWT проверяет подпись и срок действия токена, выпущенного
+// через OIDC, и возвращает sub/email из полезной нагрузки. В отличие от
+// validateJWT здесь нет пароля, с которым можно было бы сверить hash,
+// поэтому единственная проверка подлинности — валидная подпись, не
+// просроченный exp и непустой sub. Возвращаемый sub — исходный, без
+// oidcSubPrefix: вызывающему коду (и тестам) он известен как provider-side
+// sub, а префикс — деталь того, как он хранится в payload.
+func validateOIDCJWT(token string) (sub, email string, ok bool) {
+	p, err := parseAndVerifyJWTAt(token, time.Now())
+	if err != nil || p.Sub == "" {
+		return "", "", false
 	}
-	return true
+	return strings.TrimPrefix(p.Sub, oidcSubPrefix), p.Email, true
+}
+
+// makeUserJWT выпускает токен приложения для пользователя из таблицы
+// users: sub хранит user_id (как строку, по аналогии с OIDC sub), role —
+// его роль, чтобы requireAdmin (users.go) мог проверить её без похода в БД.
+func makeUserJWT(u *User) (string, error) {
+	return signJWTPayload(jwtPayload{Sub: strconv.FormatInt(u.ID, 10), Role: u.Role})
 }
 
 // makePasswordHash — примитивный sha256 от (пароль + "salt")
@@ -103,9 +136,77 @@ func makePasswordHash(password string) string {
 	return base64.RawURLEncoding.EncodeToString(sum[:])
 }
 
-func handleSignin(w http.ResponseWriter, r *http.Request) {
+func handleSignin(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
+	var req SigninRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(SigninResponse{Error: "Ошибка десериализации JSON"})
+		return
+	}
+
+	now := time.Now()
+	ip := clientIP(r)
+	throttle := getLoginThrottle()
+
+	// Ключи троттлинга: по IP всегда, плюс по логину, если он указан —
+	// так медленный брутфорс с одного IP разных логинов, и быстрый
+	// подбор пароля для одного логина с разных IP, оба упираются в лимит.
+	keys := []string{"ip:" + ip}
+	if req.Login != "" {
+		keys = append(keys, "login:"+req.Login)
+	}
+
+	if throttle != nil {
+		for _, key := range keys {
+			if locked, retryAfter := throttle.Locked(key, now); locked {
+				w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+				w.WriteHeader(http.StatusTooManyRequests)
+				json.NewEncoder(w).Encode(SigninResponse{Error: "Слишком много неудачных попыток входа, повторите позже"})
+				return
+			}
+		}
+	}
+
+	recordFailure := func() {
+		if throttle != nil {
+			for _, key := range keys {
+				throttle.RecordFailure(key, now)
+			}
+		}
+		logLoginAttempt(db, ip, req.Login, false)
+	}
+	recordSuccess := func() {
+		if throttle != nil {
+			for _, key := range keys {
+				throttle.RecordSuccess(key, now)
+			}
+		}
+		logLoginAttempt(db, ip, req.Login, true)
+	}
+
+	// Если указан логин — это вход через таблицу users (многопользовательский режим)
+	if req.Login != "" {
+		user, err := authenticateUser(db, req.Login, req.Password)
+		if err != nil {
+			recordFailure()
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(SigninResponse{Error: "Неверный логин или пароль"})
+			return
+		}
+		token, err := makeUserJWT(user)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(SigninResponse{Error: "Ошибка генерации токена"})
+			return
+		}
+		recordSuccess()
+		json.NewEncoder(w).Encode(SigninResponse{Token: token})
+		return
+	}
+
+	// Иначе — легаси-вход по общему паролю из TODO_PASSWORD
 	passEnv := os.Getenv("TODO_PASSWORD")
 	// Если переменная окружения пустая => аутентификация не нужна
 	// Но по условию, если TODO_PASSWORD пуст, формы нет и вовзращаем ошибку
@@ -116,16 +217,10 @@ func handleSignin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Читаем JSON: {"password":"..."}
-	var req SigninRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(SigninResponse{Error: "Ошибка десериализации JSON"})
-		return
-	}
-
-	// Сравниваем с passEnv
-	if req.Password != passEnv {
+	// Сравниваем с passEnv за константное время, чтобы длина совпадения
+	// не утекала через тайминг ответа
+	if subtle.ConstantTimeCompare([]byte(req.Password), []byte(passEnv)) != 1 {
+		recordFailure()
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(SigninResponse{Error: "Неверный пароль"})
 		return
@@ -139,10 +234,86 @@ func handleSignin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordSuccess()
 	// Успешная аутентификация => {"token":"..."}
 	json.NewEncoder(w).Encode(SigninResponse{Token: token})
 }
 
+// handleRefresh выпускает новый токен взамен текущего (тот же набор
+// claims, кроме iat/exp/jti) и отзывает jti старого — в отличие от
+// неявного перевыпуска в auth() (который срабатывает только в последние
+// jwtRefreshWindow до истечения), этот эндпоинт клиент может дёргать в
+// любой момент, пока токен ещё не просрочен и не отозван.
+func handleRefresh(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	c, err := r.Cookie("token")
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(SigninResponse{Error: "Отсутствует токен"})
+		return
+	}
+
+	now := time.Now()
+	payload, err := parseAndVerifyJWTAt(c.Value, now)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(SigninResponse{Error: "Невалидный или просроченный токен"})
+		return
+	}
+
+	newToken, err := signJWTPayloadAt(jwtPayload{
+		PwdHash: payload.PwdHash,
+		Sub:     payload.Sub,
+		Email:   payload.Email,
+		Role:    payload.Role,
+	}, now)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(SigninResponse{Error: "Ошибка генерации токена"})
+		return
+	}
+
+	if bl := getBlacklistStore(); bl != nil && payload.Jti != "" {
+		bl.Revoke(payload.Jti, payload.Exp)
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: "token", Value: newToken, Path: "/", HttpOnly: true})
+	json.NewEncoder(w).Encode(SigninResponse{Token: newToken})
+}
+
+// handleSignout отзывает jti текущего токена (чтобы скомпрометированный
+// или просто завершающий сессию токен нельзя было использовать повторно)
+// и очищает cookie "token".
+func handleSignout(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	c, err := r.Cookie("token")
+	if err != nil {
+		// Нечего отзывать — уже разлогинены
+		json.NewEncoder(w).Encode(map[string]any{})
+		return
+	}
+
+	jti, exp, err := jtiAndExpFromToken(c.Value, time.Now())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Некорректный токен"})
+		return
+	}
+
+	if bl := getBlacklistStore(); bl != nil && jti != "" {
+		if err := bl.Revoke(jti, exp); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка отзыва токена"})
+			return
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: "token", Value: "", Path: "/", MaxAge: -1})
+	json.NewEncoder(w).Encode(map[string]any{})
+}
+
 func auth(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		passEnv := os.Getenv("TODO_PASSWORD")
@@ -161,11 +332,37 @@ func auth(next http.HandlerFunc) http.HandlerFunc {
 		}
 
 		token := c.Value
-		if !validateJWT(token, passEnv) {
-			// Невалидный токен => 401
+		now := time.Now()
+		payload, err := parseAndVerifyJWTAt(token, now)
+		if err != nil {
+			// Невалидный или просроченный токен => 401
 			http.Error(w, "Authentification required", http.StatusUnauthorized)
 			return
 		}
+		// Токен валиден, если это либо токен по общему паролю, либо токен,
+		// выпущенный через OIDC-вход (см. handleOIDCCallback в oidc.go).
+		if payload.Sub == "" && payload.PwdHash != makePasswordHash(passEnv) {
+			http.Error(w, "Authentification required", http.StatusUnauthorized)
+			return
+		}
+
+		// Если до истечения токена осталось меньше jwtRefreshWindow,
+		// перевыпускаем cookie с тем же "содержимым", но новыми iat/exp/jti,
+		// чтобы активный пользователь не разлогинивался посередине работы.
+		if time.Unix(payload.Exp, 0).Sub(now) < jwtRefreshWindow {
+			refreshed := jwtPayload{PwdHash: payload.PwdHash, Sub: payload.Sub, Email: payload.Email, Role: payload.Role}
+			if newToken, err := signJWTPayloadAt(refreshed, now); err == nil {
+				http.SetCookie(w, &http.Cookie{Name: "token", Value: newToken, Path: "/", HttpOnly: true})
+			}
+		}
+
+		// Если sub — это user_id из таблицы users (многопользовательский
+		// режим), прокидываем его через context.Context, чтобы хендлеры
+		// (handleAddTask и т.д.) могли отфильтровать задачи по user_id, а
+		// requireAdmin (users.go) — проверить роль.
+		if userID, err := strconv.ParseInt(payload.Sub, 10, 64); err == nil && userID > 0 {
+			r = withAuthenticatedUser(r, authenticatedUser{ID: userID, Role: payload.Role})
+		}
 
 		// Всё ок => вызываем целевой хендлер
 		next(w, r)
@@ -176,7 +373,7 @@ func auth(next http.HandlerFunc) http.HandlerFunc {
 // Поле ID не было раньше, но для обновления задачи оно необходимо.
 type Task struct {
 	ID      int    `json:"id,omitempty"` // при добавлении не нужен, при обновлении — обязателен
-	Date    string `json:"date"`         // YYYYMMDD
+	Date    Date   `json:"date"`         // YYYYMMDD
 	Title   string `json:"title"`
 	Comment string `json:"comment,omitempty"`
 	Repeat  string `json:"repeat,omitempty"`
@@ -189,6 +386,9 @@ type TaskResponse struct {
 }
 
 func main() {
+	migrateDown := flag.Bool("migrate-down", false, "откатить последнюю применённую миграцию схемы и выйти")
+	flag.Parse()
+
 	port := os.Getenv("TODO_PORT")
 	if port == "" {
 		port = "7540"
@@ -197,85 +397,141 @@ func main() {
 	webDir := "./web"
 	http.Handle("/", http.FileServer(http.Dir(webDir)))
 
-	dbFile := filepath.Join(".", "scheduler.db")
-	fmt.Println("Путь к базе данных:", dbFile)
-
-	db, err := sql.Open("sqlite3", dbFile)
+	db, dbDriver, err := openConfiguredDB()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
+	fmt.Printf("БД: driver=%s\n", dbDriver)
+
+	if *migrateDown {
+		if err := rollbackLastMigration(db); err != nil {
+			log.Fatal("Ошибка отката миграции:", err)
+		}
+		fmt.Println("Миграция успешно откачена")
+		return
+	}
 
-	if err := createSchedulerTable(db); err != nil {
-		log.Fatal("Ошибка создания таблицы scheduler:", err)
+	if err := runMigrations(db); err != nil {
+		log.Fatal("Ошибка применения миграций:", err)
 	}
-	// добавил временную проверку пароля. были проблемы с установкой пароля
-	pass := os.Getenv("TODO_PASSWORD")
-	fmt.Printf("DEBUG: TODO_PASSWORD=[%s]\n", pass)
-	if pass == "" {
-		log.Println("Пароль не установлен (TODO_PASSWORD пуст)")
-	} else {
-		log.Println("Пароль установлен:", pass)
+	repo := NewTaskRepository(db, dbDriver)
+	setBlacklistStore(newBlacklistStore(db))
+	stopSweeper := startBlacklistSweeper(getBlacklistStore(), time.Hour)
+	defer stopSweeper()
+	setLoginThrottle(newLoginThrottle(db))
+	if os.Getenv("TODO_PASSWORD") == "" {
+		slog.Warn("пароль не установлен (TODO_PASSWORD пуст)")
 	}
 
 	// Маршрут для входа (авторизации)
-	http.HandleFunc("/api/signin", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/api/signin", instrumentRoute("/api/signin", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
-			handleSignin(w, r) // см. код выше
+			handleSignin(w, r, db) // см. код выше
 		} else {
 			http.NotFound(w, r)
 		}
-	})
+	}))
+
+	// Самостоятельная регистрация нового пользователя (всегда role=user)
+	http.HandleFunc("/api/signup", instrumentRoute("/api/signup", handleSignup(db)))
+
+	// Администрирование пользователей (создание/список/удаление), только для role=admin
+	//
+	// auth оборачивает instrumentRoute (а не наоборот): auth подменяет
+	// r на r с контекстом аутентифицированного пользователя через
+	// http.Request.WithContext, который возвращает новое значение, а не
+	// мутирует исходное — если бы instrumentRoute был снаружи, он видел
+	// бы старый, неаутентифицированный r и всегда логировал бы
+	// user_id=anonymous.
+	http.HandleFunc("/api/users", auth(instrumentRoute("/api/users", handleUsersCollection(db))))
+
+	// Вход через внешнего OIDC-провайдера (альтернатива общему паролю)
+	http.HandleFunc("/api/oidc/login", instrumentRoute("/api/oidc/login", handleOIDCLogin))
+	http.HandleFunc("/api/oidc/callback", instrumentRoute("/api/oidc/callback", handleOIDCCallback))
+
+	// Выход: отзывает текущий токен (jti) и чистит cookie
+	http.HandleFunc("/api/signout", instrumentRoute("/api/signout", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			handleSignout(w, r)
+		} else {
+			http.NotFound(w, r)
+		}
+	}))
+
+	// Перевыпуск токена до истечения срока действия
+	http.HandleFunc("/api/refresh", instrumentRoute("/api/refresh", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			handleRefresh(w, r)
+		} else {
+			http.NotFound(w, r)
+		}
+	}))
+
+	// Публичные ключи, которыми подписаны наши JWT — для сторонних сервисов
+	http.HandleFunc("/.well-known/jwks.json", instrumentRoute("/.well-known/jwks.json", handleJWKS))
+
+	// iCalendar-фид задач для внешних календарных клиентов (ical.go)
+	http.HandleFunc("/api/tasks.ics", instrumentRoute("/api/tasks.ics", handleTasksICS(repo)))
+	http.HandleFunc("/api/caldav/tasks", instrumentRoute("/api/caldav/tasks", handleCalDAVComplete(repo)))
 
 	// Регистрация маршрута /api/task
-	http.HandleFunc("/api/task", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/api/task", auth(instrumentRoute("/api/task", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 		// Обработка различных HTTP-методов через switch
 		switch r.Method {
 		case http.MethodPost:
-			handleAddTask(w, r, db) // для POST — добавление задачи
+			handleAddTask(w, r, repo) // для POST — добавление задачи
 		case http.MethodGet:
-			handleGetTask(w, r, db) // для GET — получение информации о задаче
+			handleGetTask(w, r, repo) // для GET — получение информации о задаче
 		case http.MethodPut:
-			handleUpdateTask(w, r, db) // для PUT — обновление задачи
+			handleUpdateTask(w, r, repo) // для PUT — обновление задачи
 		case http.MethodDelete:
-			handleDeleteTask(w, r, db) // для DELETE — удаление задачи
+			handleDeleteTask(w, r, repo) // для DELETE — удаление задачи
 		default:
 			// Если метод не поддерживается — возвращаем ошибку 405
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			json.NewEncoder(w).Encode(TaskResponse{Error: "Метод не поддерживается"})
 		}
-	})
+	})))
 
 	// Дополнительный маршрут /api/tasks для работы со списком задач (поиск и фильтры)
-	http.HandleFunc("/api/tasks", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/api/tasks", auth(instrumentRoute("/api/tasks", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			// Метод не поддерживается
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			json.NewEncoder(w).Encode(map[string]string{"error": "Метод не поддерживается"})
 			return
 		}
-		handleGetTasks(w, r, db)
-	})
+		handleGetTasks(w, r, repo)
+	})))
 
 	// Регистрация маршрута для /api/nextdate
-	http.HandleFunc("/api/nextdate", handleNextDate)
+	http.HandleFunc("/api/nextdate", instrumentRoute("/api/nextdate", handleNextDate))
 
 	// Регистрация маршрута /api/task/done — POST для отметки о выполнении
-	http.HandleFunc("/api/task/done", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/api/task/done", auth(instrumentRoute("/api/task/done", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
-			handleDoneTask(w, r, db)
+			handleDoneTask(w, r, repo)
 		} else {
 			// На все остальные методы отдаём 404 (или 405)
 			http.NotFound(w, r)
 		}
-	})
+	})))
+
+	// Метрики Prometheus и проверки живости/готовности — не оборачиваются
+	// instrumentRoute: /metrics сам является точкой скрейпа метрик, а
+	// healthz/readyz дергаются слишком часто, чтобы засорять лог и метрики
+	// каждым опросом балансировщика.
+	http.Handle("/metrics", handleMetrics())
+	http.HandleFunc("/healthz", handleHealthz)
+	http.HandleFunc("/readyz", handleReadyz(db))
 
 	// Запуск HTTP-сервера на указанном порту
-	fmt.Printf("Сервер запущен на http://localhost:%s\n", port)
+	slog.Info("сервер запущен", "addr", "http://localhost:"+port)
 	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Println("Ошибка запуска сервера:", err)
+		slog.Error("ошибка запуска сервера", "error", err)
 	}
 }
 
@@ -297,7 +553,7 @@ func createSchedulerTable(db *sql.DB) error {
 const DateFormat = "20060102"
 
 // Добавление новой задачи (POST)
-func handleAddTask(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+func handleAddTask(w http.ResponseWriter, r *http.Request, repo TaskRepository) {
 	var task Task
 	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -311,40 +567,37 @@ func handleAddTask(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		return
 	}
 
-	if task.Date == "" {
-		task.Date = time.Now().Format(DateFormat)
-	}
-
-	parsedDate, err := time.Parse(DateFormat, task.Date)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(TaskResponse{Error: "Неверный формат даты"})
-		return
+	if task.Date.IsZero() {
+		task.Date = Date(time.Now())
 	}
 
 	now := time.Now()
 	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 
-	if parsedDate.Before(today) {
+	if task.Date.Time().Before(today) {
 		if task.Repeat == "" {
 			// Если нет повторения, просто устанавливаем дату на сегодня
-			task.Date = today.Format(DateFormat)
+			task.Date = Date(today)
 		} else {
 			// Если есть повторение, двигаем дату с помощью NextDate
-			nextDate, err := NextDate(today, task.Date, task.Repeat)
+			nextDate, err := NextDate(today, task.Date.String(), task.Repeat)
 			if err != nil {
 				w.WriteHeader(http.StatusBadRequest)
 				json.NewEncoder(w).Encode(TaskResponse{Error: "Неверное правило повторения"})
 				return
 			}
-			task.Date = nextDate
+			task.Date, err = ParseDate(nextDate)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(TaskResponse{Error: "Ошибка вычисления даты повторения"})
+				return
+			}
 		}
 	}
 
-	res, err := db.Exec(`
-		INSERT INTO scheduler (title, date, comment, repeat) 
-		VALUES (?, ?, ?, ?)`,
-		task.Title, task.Date, task.Comment, task.Repeat)
+	userID, scoped := scopedUserID(r)
+
+	id, err := repo.Insert(task.Title, task.Date, task.Comment, task.Repeat, userID, scoped)
 	if err != nil {
 		log.Printf("Ошибка добавления задачи: Title=%s, Date=%s, Comment=%s, Repeat=%s, error: %v",
 			task.Title, task.Date, task.Comment, task.Repeat, err)
@@ -353,40 +606,28 @@ func handleAddTask(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		return
 	}
 
-	id, err := res.LastInsertId()
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(TaskResponse{Error: "Ошибка получения ID задачи"})
-		return
-	}
-
 	json.NewEncoder(w).Encode(TaskResponse{ID: int(id)})
 	log.Printf("Задача добавлена: ID=%d, Title=%s", id, task.Title)
 }
 
 // Получение одной задачи (GET /api/task?id=...)
-func handleGetTask(w http.ResponseWriter, r *http.Request, db *sql.DB) {
-	taskID := r.URL.Query().Get("id")
-	if taskID == "" {
+func handleGetTask(w http.ResponseWriter, r *http.Request, repo TaskRepository) {
+	taskIDStr := r.URL.Query().Get("id")
+	if taskIDStr == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Не указан идентификатор"})
 		return
 	}
 
-	var (
-		id      int
-		date    string
-		title   string
-		comment string
-		repeat  string
-	)
-
-	err := db.QueryRow(`
-		SELECT id, date, title, comment, repeat 
-		FROM scheduler 
-		WHERE id = ?`, taskID).
-		Scan(&id, &date, &title, &comment, &repeat)
+	taskID, err := strconv.Atoi(taskIDStr)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Некорректный формат ID задачи"})
+		return
+	}
 
+	userID, scoped := scopedUserID(r)
+	td, err := repo.Get(taskID, userID, scoped)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			w.WriteHeader(http.StatusNotFound)
@@ -398,15 +639,6 @@ func handleGetTask(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		return
 	}
 
-	// Возвращаем в удобном формате JSON с ID как строкой
-	td := Task{
-		ID:      id,
-		Date:    date,
-		Title:   title,
-		Comment: comment,
-		Repeat:  repeat,
-	}
-
 	json.NewEncoder(w).Encode(td)
 }
 
@@ -418,7 +650,7 @@ type UpdateRequest struct {
 	Repeat  string `json:"repeat"`
 }
 
-func handleUpdateTask(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+func handleUpdateTask(w http.ResponseWriter, r *http.Request, repo TaskRepository) {
 	var req UpdateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		// Ошибка десериализации
@@ -436,117 +668,81 @@ func handleUpdateTask(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		return
 	}
 
+	incomingDate, err := ParseDate(req.Date)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(TaskResponse{Error: "Неверный формат даты"})
+		return
+	}
+
 	// Теперь соберём структуру Task (у вас в БД int, значит приводим к int)
 	incoming := Task{
 		ID:      int(idNum),
-		Date:    req.Date,
+		Date:    incomingDate,
 		Title:   req.Title,
 		Comment: req.Comment,
 		Repeat:  req.Repeat,
 	}
 
-	// 2. Посмотрим, что реально пришло
-	log.Printf("DEBUG: incoming => ID=%d, Date=%q, Title=%q, Comment=%q, Repeat=%q\n",
-		incoming.ID, incoming.Date, incoming.Title, incoming.Comment, incoming.Repeat)
-
-	// 3. Проверяем ID
+	// 2. Проверяем ID
 	if incoming.ID <= 0 {
-		log.Println("DEBUG: ID is 0 or negative => returning error")
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(TaskResponse{Error: "Не указан идентификатор задачи"})
 		return
 	}
 
-	// 4. Проверяем title
+	// 3. Проверяем title
 	if incoming.Title == "" {
-		log.Println("DEBUG: title is empty => returning error")
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(TaskResponse{Error: "Не указан заголовок задачи"})
 		return
 	}
 
-	// 5. Если дата пустая => ставим today's date (по условию теста)
-	if incoming.Date == "" {
-		incoming.Date = time.Now().Format(DateFormat)
-		log.Printf("DEBUG: date was empty => set to today %q\n", incoming.Date)
-	}
-
-	// 6. Парсим дату
-	parsedDate, err := time.Parse(DateFormat, incoming.Date)
-	if err != nil {
-		log.Printf("DEBUG: invalid date => %q\n", incoming.Date)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(TaskResponse{Error: "Неверный формат даты"})
-		return
+	// 4. Если дата пустая => ставим today's date (по условию теста)
+	if incoming.Date.IsZero() {
+		incoming.Date = Date(time.Now())
 	}
 
-	// 7. Проверяем, что дата >= сегодня
+	// 5. Проверяем, что дата >= сегодня
 	now := time.Now()
 	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	if parsedDate.Before(today) {
-		log.Printf("DEBUG: date < today => returning error, date=%q\n", incoming.Date)
+	if incoming.Date.Time().Before(today) {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(TaskResponse{Error: "Дата не может быть меньше сегодняшней"})
 		return
 	}
 
-	// 8. Проверяем repeat, если не пустой
+	// 6. Проверяем repeat, если не пустой
 	if incoming.Repeat != "" {
-		if strings.HasPrefix(incoming.Repeat, "d ") {
-			daysStr := strings.TrimSpace(strings.TrimPrefix(incoming.Repeat, "d "))
-			days, err := strconv.Atoi(daysStr)
-			if err != nil || days <= 0 {
-				log.Printf("DEBUG: bad repeat => %q\n", incoming.Repeat)
-				w.WriteHeader(http.StatusBadRequest)
-				json.NewEncoder(w).Encode(TaskResponse{Error: "Неверное правило повторения"})
-				return
-			}
-		} else if incoming.Repeat != "y" {
-			log.Printf("DEBUG: bad repeat => %q\n", incoming.Repeat)
+		if err := validateRepeatRule(incoming.Repeat); err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			json.NewEncoder(w).Encode(TaskResponse{Error: "Неверное правило повторения"})
 			return
 		}
 	}
 
-	// 9. Если все проверки пройдены => идём делать UPDATE
-	log.Printf("DEBUG: going to UPDATE. ID=%d Title=%q Date=%q Comment=%q Repeat=%q",
-		incoming.ID, incoming.Title, incoming.Date, incoming.Comment, incoming.Repeat)
-
-	res, err := db.Exec(`
-        UPDATE scheduler
-        SET title=?, date=?, comment=?, repeat=?
-        WHERE id=?
-    `, incoming.Title, incoming.Date, incoming.Comment, incoming.Repeat, incoming.ID)
+	// 7. Если все проверки пройдены => идём делать UPDATE
+	userID, scoped := scopedUserID(r)
+	rowsAffected, err := repo.Update(incoming, userID, scoped)
 	if err != nil {
-		log.Printf("ERROR: db.Exec failed: %v\n", err)
+		slog.Error("ошибка обновления задачи", "task_id", incoming.ID, "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(TaskResponse{Error: "Ошибка обновления задачи в базе данных"})
 		return
 	}
 
-	rowsAffected, err := res.RowsAffected()
-	if err != nil {
-		log.Printf("ERROR: rowsAffected error: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(TaskResponse{Error: "Ошибка получения результата обновления"})
-		return
-	}
-
 	if rowsAffected == 0 {
-		log.Printf("DEBUG: rowsAffected == 0 => id not found = %d\n", incoming.ID)
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(TaskResponse{Error: "Задача не найдена"})
 		return
 	}
 
-	// 10. Всё ок
-	log.Println("DEBUG: UPDATE success => returning empty JSON")
+	// 8. Всё ок
 	json.NewEncoder(w).Encode(map[string]any{})
 }
 
 // удаление задачи
-func handleDeleteTask(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+func handleDeleteTask(w http.ResponseWriter, r *http.Request, repo TaskRepository) {
 	taskIdStr := r.URL.Query().Get("id")
 	if taskIdStr == "" {
 		w.WriteHeader(http.StatusBadRequest)
@@ -561,20 +757,13 @@ func handleDeleteTask(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		return
 	}
 
-	// Пытаемся удалить
-	res, err := db.Exec("DELETE FROM scheduler WHERE id = ?", taskId)
+	userID, scoped := scopedUserID(r)
+	rowsAffected, err := repo.Delete(taskId, userID, scoped)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(TaskResponse{Error: "Ошибка удаления задачи"})
 		return
 	}
-
-	rowsAffected, err := res.RowsAffected()
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(TaskResponse{Error: "Ошибка получения результата удаления"})
-		return
-	}
 	if rowsAffected == 0 {
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(TaskResponse{Error: "Задача не найдена"})
@@ -586,7 +775,7 @@ func handleDeleteTask(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 }
 
 // handleDoneTask обрабатывает POST /api/task/done?id=<id>
-func handleDoneTask(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+func handleDoneTask(w http.ResponseWriter, r *http.Request, repo TaskRepository) {
 	// 1. Получаем ID задачи из query
 	taskIdStr := r.URL.Query().Get("id")
 	if taskIdStr == "" {
@@ -602,13 +791,9 @@ func handleDoneTask(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 		return
 	}
 
-	// 2. Читаем задачу из БД
-	var t Task
-	err = db.QueryRow(`
-        SELECT id, date, title, comment, repeat 
-        FROM scheduler 
-        WHERE id = ?
-    `, taskID).Scan(&t.ID, &t.Date, &t.Title, &t.Comment, &t.Repeat)
+	// 2. Читаем задачу из БД (с учётом изоляции по user_id)
+	userID, scoped := scopedUserID(r)
+	t, err := repo.Get(taskID, userID, scoped)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			w.WriteHeader(http.StatusNotFound)
@@ -622,9 +807,7 @@ func handleDoneTask(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 
 	// 3. Если repeat пустой => задача одноразовая => УДАЛЯЕМ
 	if t.Repeat == "" {
-		// удаляем
-		_, err := db.Exec("DELETE FROM scheduler WHERE id = ?", taskID)
-		if err != nil {
+		if _, err := repo.Delete(taskID, userID, scoped); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(TaskResponse{Error: "Ошибка удаления задачи из БД"})
 			return
@@ -636,8 +819,19 @@ func handleDoneTask(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 
 	// 4. Иначе задача периодическая => считаем новую дату через NextDate()
 	now := time.Now()
-	newDate, err := NextDate(now, t.Date, t.Repeat)
+	newDate, err := NextDate(now, t.Date.String(), t.Repeat)
 	if err != nil {
+		if errors.Is(err, ErrSeriesEnded) {
+			// Серия исчерпана (UNTIL/COUNT) — дальше откладывать некуда,
+			// поэтому ведём себя так же, как с одноразовой задачей: удаляем.
+			if _, err := repo.Delete(taskID, userID, scoped); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(TaskResponse{Error: "Ошибка удаления задачи из БД"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{})
+			return
+		}
 		// Если по каким-то причинам NextDate не смогла вычислить (например, repeat кривой),
 		// вернём ошибку. Хотя по тестам это вряд ли случится, так как repeat уже валидный.
 		w.WriteHeader(http.StatusBadRequest)
@@ -646,12 +840,13 @@ func handleDoneTask(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	}
 
 	// 5. Обновляем date в БД
-	_, err = db.Exec(`
-        UPDATE scheduler 
-        SET date = ? 
-        WHERE id = ?
-    `, newDate, taskID)
+	t.Date, err = ParseDate(newDate)
 	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(TaskResponse{Error: "Ошибка вычисления даты повторения"})
+		return
+	}
+	if _, err := repo.Update(t, userID, scoped); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(TaskResponse{Error: "Ошибка обновления задачи в БД"})
 		return
@@ -662,7 +857,7 @@ func handleDoneTask(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 }
 
 // handleGetTasks — получение списка задач (сортировка, поиск и т. д.)
-func handleGetTasks(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+func handleGetTasks(w http.ResponseWriter, r *http.Request, repo TaskRepository) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	const limitDefault = 50
@@ -670,88 +865,31 @@ func handleGetTasks(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	search := r.URL.Query().Get("search")
 	search = strings.TrimSpace(search)
 
+	userID, scoped := scopedUserID(r)
+
 	var (
-		query string
-		args  []any
+		tasks []Task
+		err   error
 	)
 
 	if search == "" {
 		// без параметра search — все задачи
-		query = `
-			SELECT id, date, title, comment, repeat
-			FROM scheduler
-			ORDER BY date ASC
-			LIMIT ?
-		`
-		args = append(args, limitDefault)
+		tasks, err = repo.ListRecent(limitDefault, userID, scoped)
+	} else if parsedDate, parseErr := time.Parse(DateFormat, search); parseErr == nil {
+		// search — дата формата dd.mm.yyyy
+		tasks, err = repo.ListByDate(parsedDate.Format(DateFormat), limitDefault, userID, scoped)
 	} else {
-		// проверим, не является ли search датой формата dd.mm.yyyy
-		parsedDate, err := time.Parse(DateFormat, search)
-		if err == nil {
-			dateForDB := parsedDate.Format(DateFormat)
-			query = `
-				SELECT id, date, title, comment, repeat
-				FROM scheduler
-				WHERE date = ?
-				ORDER BY date ASC
-				LIMIT ?
-			`
-			args = append(args, dateForDB, limitDefault)
-		} else {
-			// ищем подстроку в title или comment
-			likePattern := fmt.Sprintf("%%%s%%", search)
-			query = `
-				SELECT id, date, title, comment, repeat
-				FROM scheduler
-				WHERE title LIKE ? OR comment LIKE ?
-				ORDER BY date ASC
-				LIMIT ?
-			`
-			args = append(args, likePattern, likePattern, limitDefault)
-		}
+		// ищем подстроку в title или comment
+		likePattern := fmt.Sprintf("%%%s%%", search)
+		tasks, err = repo.ListByText(likePattern, limitDefault, userID, scoped)
 	}
 
-	rows, err := db.Query(query, args...)
 	if err != nil {
 		log.Println("Ошибка при запросе списка задач:", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка получения списка задач"})
 		return
 	}
-	defer rows.Close()
-
-	var tasks []Task
-
-	for rows.Next() {
-		var (
-			id      int
-			dateStr string
-			title   string
-			comment string
-			repeat  string
-		)
-
-		if err := rows.Scan(&id, &dateStr, &title, &comment, &repeat); err != nil {
-			log.Println("Ошибка чтения данных задачи:", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка чтения задач из базы"})
-			return
-		}
-
-		tasks = append(tasks, Task{
-			ID:      id,
-			Date:    dateStr,
-			Title:   title,
-			Comment: comment,
-			Repeat:  repeat,
-		})
-	}
-	if err := rows.Err(); err != nil {
-		log.Println("Ошибка итерации по строкам:", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка итерации по задачам"})
-		return
-	}
 
 	// Если ничего не нашли, tasks == nil => пустой срез
 	if tasks == nil {
@@ -768,11 +906,65 @@ func NextDate(now time.Time, date string, repeat string) (string, error) {
 	if repeat == "" {
 		return "", fmt.Errorf("пустое правило повторения")
 	}
-	parsedDate, err := time.Parse(DateFormat, date)
+	parsedDate, err := ParseTaskDate(date)
 	if err != nil {
 		return "", fmt.Errorf("некорректная дата: %s", date)
 	}
+	// Приводим дату задачи к часовому поясу now, чтобы обе даты в
+	// арифметике ниже были в одной и той же зоне — иначе "сегодня" для
+	// правила m "-1" или переход через 29 февраля зависел бы от того, в
+	// какой зоне изначально распарсилась date (обычно UTC).
+	parsedDate = parsedDate.In(now.Location())
+
+	if isRRule(repeat) {
+		rule, err := parseRRule(repeat)
+		if err != nil {
+			return "", err
+		}
+		return nextRRuleDate(rule, parsedDate, now)
+	}
+
+	// Компактная грамматика (в отличие от RRULE) хранит UNTIL/COUNT не
+	// внутри правила, а отдельным суффиксом — splitRepeatTerminator
+	// отделяет его перед разбором самого правила.
+	baseRepeat, term, err := splitRepeatTerminator(repeat)
+	if err != nil {
+		return "", err
+	}
+
+	nextDateStr, err := computeNextOccurrence(now, parsedDate, baseRepeat)
+	if err != nil {
+		return "", err
+	}
+
+	if !term.until.IsZero() || term.count > 0 {
+		nextDate, err := ParseTaskDate(nextDateStr)
+		if err != nil {
+			return "", err
+		}
+		nextDate = nextDate.In(now.Location())
 
+		if !term.until.IsZero() && nextDate.After(term.until.In(now.Location())) {
+			return "", ErrSeriesEnded
+		}
+		if term.count > 0 {
+			occurrence, err := countOccurrences(parsedDate, nextDate, baseRepeat)
+			if err != nil {
+				return "", err
+			}
+			if occurrence > term.count {
+				return "", ErrSeriesEnded
+			}
+		}
+	}
+
+	return nextDateStr, nil
+}
+
+// computeNextOccurrence — вычисление ближайшей даты после now по
+// компактной грамматике d/y/w/m/every, без учёта терминаторов UNTIL/COUNT
+// (см. splitRepeatTerminator и NextDate).
+func computeNextOccurrence(now, parsedDate time.Time, repeat string) (string, error) {
 	nextDate := parsedDate
 
 	switch {
@@ -837,6 +1029,29 @@ func NextDate(now time.Time, date string, repeat string) (string, error) {
 			}
 		}
 
+	case strings.HasPrefix(repeat, "w "):
+		rule, err := parseWeekRule(repeat)
+		if err != nil {
+			return "", err
+		}
+		return searchNextMatch(repeatSearchStart(parsedDate, now), rule.matches)
+
+	case strings.HasPrefix(repeat, "m "):
+		rule, err := parseMonthRule(repeat)
+		if err != nil {
+			return "", err
+		}
+		return searchNextMatch(repeatSearchStart(parsedDate, now), rule.matches)
+
+	case strings.HasPrefix(repeat, "every "):
+		rule, err := parseEveryWeeksRule(repeat)
+		if err != nil {
+			return "", err
+		}
+		return searchNextMatch(repeatSearchStart(parsedDate, now), func(t time.Time) bool {
+			return rule.matches(parsedDate, t)
+		})
+
 	default:
 		return "", fmt.Errorf("неподдерживаемый формат: %s", repeat)
 	}
@@ -849,31 +1064,51 @@ func isLeapYear(year int) bool {
 	return (year%4 == 0 && year%100 != 0) || (year%400 == 0)
 }
 
-func handleNextDate(w http.ResponseWriter, r *http.Request) {
-	// Тест ожидает обычный текст (не JSON):
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+// writeNextDateError отдаёт JSON {"error": "..."} с кодом 400 — раньше
+// любая ошибка разбора даты молча превращалась в пустое тело 200, что
+// клиентам API было невозможно отличить от "правило не даёт следующей
+// даты".
+func writeNextDateError(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
 
+func handleNextDate(w http.ResponseWriter, r *http.Request) {
 	nowStr := r.URL.Query().Get("now")
 	dateStr := r.URL.Query().Get("date")
 	repeatStr := r.URL.Query().Get("repeat")
 
-	// Парсим nowStr как "20060102"
-	nowTime, err := time.Parse(DateFormat, nowStr)
+	loc := time.Local
+	if tzStr := r.URL.Query().Get("tz"); tzStr != "" {
+		l, err := time.LoadLocation(tzStr)
+		if err != nil {
+			writeNextDateError(w, fmt.Sprintf("неизвестный часовой пояс: %v", err))
+			return
+		}
+		loc = l
+	}
+
+	nowTime, err := ParseTaskDate(nowStr)
 	if err != nil {
-		// Если невалидная дата now => возвращаем пустую строку
-		// (согласно логике теста "если ошибка => пустая строка")
-		w.Write([]byte(""))
+		writeNextDateError(w, fmt.Sprintf("некорректный параметр now: %v", err))
 		return
 	}
+	nowTime = nowTime.In(loc)
 
-	// Вызываем вашу функцию NextDate
 	next, err := NextDate(nowTime, dateStr, repeatStr)
 	if err != nil {
-		// Если NextDate вернула ошибку => тоже пустую строку
-		w.Write([]byte(""))
+		if errors.Is(err, ErrSeriesEnded) {
+			// Серия исчерпана (UNTIL/COUNT) — это не ошибка разбора, поэтому
+			// не 400 с JSON-телом, а пустой 204: "следующей даты нет".
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeNextDateError(w, err.Error())
 		return
 	}
 
-	// Если всё ок, возвращаем найденную дату
+	// Тест ожидает обычный текст (не JSON) при успешном ответе.
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.Write([]byte(next))
 }
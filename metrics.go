@@ -0,0 +1,100 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// httpRequestsTotal и httpRequestDuration — метрики HTTP-слоя. route — это
+// шаблон маршрута (как зарегистрирован в main(), например "/api/task"), а
+// не сырой URL с query-параметрами, иначе кардинальность улетит в небо.
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Общее число HTTP-запросов по маршруту, методу и статусу ответа.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Время обработки HTTP-запроса.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	dbOperationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_operations_total",
+		Help: "Число обращений к БД по операции и результату (ok/error).",
+	}, []string{"operation", "result"})
+
+	dbOperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_operation_duration_seconds",
+		Help:    "Время выполнения запроса к БД.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+)
+
+// handleMetrics отдаёт /metrics для скрейпа Prometheus.
+func handleMetrics() http.Handler {
+	return promhttp.Handler()
+}
+
+// handleHealthz — liveness: процесс жив и отвечает, БД не проверяется.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// handleReadyz — readiness: процесс готов обслуживать трафик, только если
+// БД отвечает на пинг.
+func handleReadyz(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := db.PingContext(r.Context()); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("db unavailable: " + err.Error()))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}
+}
+
+func observeDBOperation(operation string, duration time.Duration, err error) {
+	result := "ok"
+	if err != nil && err != sql.ErrNoRows {
+		result = "error"
+	}
+	dbOperationsTotal.WithLabelValues(operation, result).Inc()
+	dbOperationDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+// dbExec оборачивает db.Exec метриками db_operations_total /
+// db_operation_duration_seconds — operation обычно имя таблицы и действия
+// (например "scheduler_insert"), не сырой SQL, опять же из-за кардинальности.
+func dbExec(db *sql.DB, operation, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	res, err := db.Exec(query, args...)
+	observeDBOperation(operation, time.Since(start), err)
+	return res, err
+}
+
+// dbQuery оборачивает db.Query той же парой метрик.
+func dbQuery(db *sql.DB, operation, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := db.Query(query, args...)
+	observeDBOperation(operation, time.Since(start), err)
+	return rows, err
+}
+
+// dbQueryRow оборачивает db.QueryRow — у него нет возвращаемой ошибки до
+// Scan, поэтому метрика результата здесь всегда "ok" (ошибка, если она
+// есть, всплывёт и будет учтена в Scan вызывающей стороной отдельно).
+func dbQueryRow(db *sql.DB, operation, query string, args ...any) *sql.Row {
+	start := time.Now()
+	row := db.QueryRow(query, args...)
+	observeDBOperation(operation, time.Since(start), nil)
+	return row
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const requestIDHeader = "X-Request-Id"
+
+// statusRecorder перехватывает код ответа, который обработчик передаёт в
+// WriteHeader, чтобы middleware могла залогировать и учесть его в
+// метриках — net/http сам по себе этого не отдаёт.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// requestIDFor берёт X-Request-Id из входящего запроса (если клиент уже
+// его проставил, например для сквозной трассировки через прокси) или
+// генерирует новый.
+func requestIDFor(r *http.Request) string {
+	if id := r.Header.Get(requestIDHeader); id != "" {
+		return id
+	}
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// instrumentRoute оборачивает обработчик структурным логом (log/slog) и
+// Prometheus-метриками http_requests_total/http_request_duration_seconds.
+// route — шаблон маршрута, под которым он зарегистрирован в main(), а не
+// сырой r.URL.Path (чтобы не раздувать кардинальность метрик параметрами
+// запроса).
+func instrumentRoute(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		reqID := requestIDFor(r)
+		w.Header().Set(requestIDHeader, reqID)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		duration := time.Since(start)
+
+		var userID any = "anonymous"
+		if id, scoped := scopedUserID(r); scoped {
+			userID = id
+		}
+
+		slog.Info("http_request",
+			"method", r.Method,
+			"route", route,
+			"status", rec.status,
+			"duration_ms", duration.Milliseconds(),
+			"user_id", userID,
+			"request_id", reqID,
+		)
+
+		httpRequestsTotal.WithLabelValues(route, r.Method, strconv.Itoa(rec.status)).Inc()
+		httpRequestDuration.WithLabelValues(route, r.Method).Observe(duration.Seconds())
+	}
+}
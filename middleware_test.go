@@ -0,0 +1,66 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestInstrumentRouteRecordsStatusAndRequestID(t *testing.T) {
+	handler := instrumentRoute("/api/test", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/test", nil)
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if w.Code != http.StatusTeapot {
+		t.Errorf("status = %d, ожидали %d", w.Code, http.StatusTeapot)
+	}
+	if w.Header().Get(requestIDHeader) == "" {
+		t.Error("ожидали непустой заголовок X-Request-Id")
+	}
+}
+
+func TestInstrumentRoutePreservesIncomingRequestID(t *testing.T) {
+	handler := instrumentRoute("/api/test", func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/test", nil)
+	req.Header.Set(requestIDHeader, "fixed-id")
+	w := httptest.NewRecorder()
+	handler(w, req)
+
+	if got := w.Header().Get(requestIDHeader); got != "fixed-id" {
+		t.Errorf("X-Request-Id = %q, ожидали %q", got, "fixed-id")
+	}
+}
+
+func TestHandleHealthz(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	w := httptest.NewRecorder()
+	handleHealthz(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, ожидали 200", w.Code)
+	}
+}
+
+func TestHandleReadyz(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	w := httptest.NewRecorder()
+	handleReadyz(db)(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, ожидали 200", w.Code)
+	}
+}
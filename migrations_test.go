@@ -0,0 +1,76 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func setupMigrationsTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestRunMigrationsAppliesEachOnceAndIsIdempotent(t *testing.T) {
+	db := setupMigrationsTestDB(t)
+
+	if err := runMigrations(db); err != nil {
+		t.Fatalf("runMigrations вернул ошибку: %v", err)
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != len(migrations) {
+		t.Errorf("ожидали %d применённых миграций, получили %d", len(migrations), count)
+	}
+
+	// scheduler должна быть работоспособна после миграций
+	repo := NewTaskRepository(db, dbDriverSQLite)
+	date, err := ParseDate("20260101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Insert("Задача", date, "", "", 0, false); err != nil {
+		t.Fatalf("таблица scheduler не готова после миграций: %v", err)
+	}
+
+	// Повторный запуск не должен пытаться применить миграции заново
+	if err := runMigrations(db); err != nil {
+		t.Fatalf("повторный runMigrations вернул ошибку: %v", err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != len(migrations) {
+		t.Errorf("повторный runMigrations не должен добавлять новые записи, получили %d", count)
+	}
+}
+
+func TestRollbackLastMigrationFailsWhenIrreversible(t *testing.T) {
+	db := setupMigrationsTestDB(t)
+
+	if err := runMigrations(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Последняя миграция (создание login_audit) необратима — Down не задан.
+	if err := rollbackLastMigration(db); err == nil {
+		t.Error("ожидали ошибку при откате миграции без Down")
+	}
+}
+
+func TestRollbackLastMigrationFailsWithoutAppliedMigrations(t *testing.T) {
+	db := setupMigrationsTestDB(t)
+
+	if err := rollbackLastMigration(db); err == nil {
+		t.Error("ожидали ошибку при откате без применённых миграций")
+	}
+}
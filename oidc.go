@@ -0,0 +1,394 @@
+package main
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// oidcDiscovery — нужные нам поля из /.well-known/openid-configuration
+type oidcDiscovery struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSUri               string `json:"jwks_uri"`
+}
+
+// oidcTokenResponse — ответ token_endpoint при обмене code->token
+type oidcTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+	Error       string `json:"error"`
+}
+
+// oidcUserInfo — то, что нам нужно с userinfo_endpoint
+type oidcUserInfo struct {
+	Sub   string `json:"sub"`
+	Email string `json:"email"`
+}
+
+// oidcConfig читает настройки провайдера из переменных окружения.
+// Если OIDC_ISSUER пуст, SSO считается не настроенным.
+func oidcConfig() (issuer, clientID, clientSecret, redirectURL string, ok bool) {
+	issuer = os.Getenv("OIDC_ISSUER")
+	clientID = os.Getenv("OIDC_CLIENT_ID")
+	clientSecret = os.Getenv("OIDC_CLIENT_SECRET")
+	redirectURL = os.Getenv("OIDC_REDIRECT_URL")
+	ok = issuer != "" && clientID != "" && redirectURL != ""
+	return
+}
+
+func fetchOIDCDiscovery(issuer string) (*oidcDiscovery, error) {
+	resp, err := http.Get(strings.TrimRight(issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения OIDC discovery: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var disc oidcDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&disc); err != nil {
+		return nil, fmt.Errorf("ошибка разбора OIDC discovery: %w", err)
+	}
+	return &disc, nil
+}
+
+var (
+	stateSecretOnce sync.Once
+	stateSecret     []byte
+)
+
+// stateHMACSecret — случайный ключ, сгенерированный один раз за время
+// жизни процесса. В отличие от makePasswordHash (который солит публичной
+// константой и годится только для сравнения с TODO_PASSWORD), подпись
+// oidc_state должна опираться на секрет, недоступный из одного только
+// исходного кода — иначе её может вычислить кто угодно.
+func stateHMACSecret() []byte {
+	stateSecretOnce.Do(func() {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+		stateSecret = b
+	})
+	return stateSecret
+}
+
+// signState подписывает state HMAC-SHA256 на stateHMACSecret, чтобы
+// /api/oidc/callback мог убедиться, что cookie не подделана.
+func signState(state string) string {
+	mac := hmac.New(sha256.New, stateHMACSecret())
+	mac.Write([]byte(state))
+	return state + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func verifyState(cookieValue string) (state string, ok bool) {
+	parts := strings.SplitN(cookieValue, ".", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	mac := hmac.New(sha256.New, stateHMACSecret())
+	mac.Write([]byte(parts[0]))
+	expected := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(parts[1]), []byte(expected)) {
+		return "", false
+	}
+	return parts[0], true
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// handleOIDCLogin инициирует authorization code flow: редиректит
+// пользователя на authorization_endpoint провайдера и выставляет
+// подписанную cookie "oidc_state" для последующей проверки в callback'е.
+func handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	issuer, clientID, _, redirectURL, ok := oidcConfig()
+	if !ok {
+		http.Error(w, "OIDC не настроен", http.StatusNotImplemented)
+		return
+	}
+
+	disc, err := fetchOIDCDiscovery(issuer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	state, err := randomState()
+	if err != nil {
+		http.Error(w, "Ошибка генерации state", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "oidc_state",
+		Value:    signState(state),
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   600,
+	})
+
+	authURL := disc.AuthorizationEndpoint + "?" + url.Values{
+		"response_type": {"code"},
+		"client_id":     {clientID},
+		"redirect_uri":  {redirectURL},
+		"scope":         {"openid email profile"},
+		"state":         {state},
+	}.Encode()
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// handleOIDCCallback обрабатывает редирект от провайдера: сверяет state,
+// меняет code на токены, проверяет id_token и выставляет обычную cookie
+// "token" с JWT приложения, привязанным к sub/email пользователя.
+func handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	issuer, clientID, clientSecret, redirectURL, ok := oidcConfig()
+	if !ok {
+		http.Error(w, "OIDC не настроен", http.StatusNotImplemented)
+		return
+	}
+
+	stateCookie, err := r.Cookie("oidc_state")
+	if err != nil {
+		http.Error(w, "Отсутствует state cookie", http.StatusBadRequest)
+		return
+	}
+	state, ok := verifyState(stateCookie.Value)
+	if !ok || state != r.URL.Query().Get("state") {
+		http.Error(w, "Неверный state", http.StatusBadRequest)
+		return
+	}
+
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		http.Error(w, "Отсутствует code", http.StatusBadRequest)
+		return
+	}
+
+	disc, err := fetchOIDCDiscovery(issuer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	tok, err := exchangeCodeForToken(disc, code, clientID, clientSecret, redirectURL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	sub, email, err := verifyIDToken(disc, tok.IDToken, clientID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Не удалось проверить id_token: %v", err), http.StatusUnauthorized)
+		return
+	}
+	if sub == "" && tok.AccessToken != "" {
+		// провайдер может не вернуть email в id_token — добираем через userinfo
+		if info, err := fetchUserInfo(disc, tok.AccessToken); err == nil {
+			sub, email = info.Sub, info.Email
+		}
+	}
+	if sub == "" {
+		http.Error(w, "Провайдер не вернул sub", http.StatusUnauthorized)
+		return
+	}
+
+	appToken, err := makeOIDCJWT(sub, email)
+	if err != nil {
+		http.Error(w, "Ошибка генерации токена", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "token",
+		Value:    appToken,
+		Path:     "/",
+		HttpOnly: true,
+	})
+	http.SetCookie(w, &http.Cookie{Name: "oidc_state", Value: "", Path: "/", MaxAge: -1})
+
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func exchangeCodeForToken(disc *oidcDiscovery, code, clientID, clientSecret, redirectURL string) (*oidcTokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+	}
+	resp, err := http.PostForm(disc.TokenEndpoint, form)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка обмена code на токен: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tok oidcTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("ошибка разбора ответа token_endpoint: %w", err)
+	}
+	if tok.Error != "" {
+		return nil, fmt.Errorf("token_endpoint вернул ошибку: %s", tok.Error)
+	}
+	return &tok, nil
+}
+
+func fetchUserInfo(disc *oidcDiscovery, accessToken string) (*oidcUserInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, disc.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса userinfo: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var info oidcUserInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("ошибка разбора userinfo: %w", err)
+	}
+	return &info, nil
+}
+
+// jwk — одна запись из JWKS провайдера (поддерживаем только RSA/RS256).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+func fetchJWKS(jwksURI string) (*jwkSet, error) {
+	resp, err := http.Get(jwksURI)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var set jwkSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("ошибка разбора JWKS: %w", err)
+	}
+	return &set, nil
+}
+
+func jwkToPublicKey(k jwk) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	e := 0
+	for _, b := range eBytes {
+		e = e<<8 | int(b)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nBytes), E: e}, nil
+}
+
+// verifyIDToken разбирает id_token (header.payload.signature), находит
+// подходящий ключ в JWKS провайдера по kid и проверяет подпись RS256.
+func verifyIDToken(disc *oidcDiscovery, idToken string, clientID string) (sub, email string, err error) {
+	if idToken == "" {
+		return "", "", errors.New("пустой id_token")
+	}
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return "", "", errors.New("некорректный формат id_token")
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", "", err
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return "", "", err
+	}
+	if header.Alg != "RS256" {
+		return "", "", fmt.Errorf("неподдерживаемый alg: %s", header.Alg)
+	}
+
+	set, err := fetchJWKS(disc.JWKSUri)
+	if err != nil {
+		return "", "", err
+	}
+	var key *jwk
+	for i := range set.Keys {
+		if set.Keys[i].Kid == header.Kid {
+			key = &set.Keys[i]
+			break
+		}
+	}
+	if key == nil {
+		return "", "", fmt.Errorf("ключ %q не найден в JWKS", header.Kid)
+	}
+	pub, err := jwkToPublicKey(*key)
+	if err != nil {
+		return "", "", err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", "", err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+		return "", "", fmt.Errorf("подпись id_token не прошла проверку: %w", err)
+	}
+
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", "", err
+	}
+	var claims struct {
+		Sub   string `json:"sub"`
+		Email string `json:"email"`
+		Aud   string `json:"aud"`
+		Exp   int64  `json:"exp"`
+	}
+	if err := json.Unmarshal(payloadBytes, &claims); err != nil {
+		return "", "", err
+	}
+	if claims.Aud != clientID {
+		return "", "", errors.New("aud в id_token не совпадает с client_id")
+	}
+	if claims.Exp != 0 && time.Now().Unix() > claims.Exp {
+		return "", "", errors.New("id_token истёк")
+	}
+
+	return claims.Sub, claims.Email, nil
+}
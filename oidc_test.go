@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// signTestIDToken собирает RS256 id_token с заданными claims, подписанный
+// тестовым RSA-ключом, и отдаёт заодно JWKS-представление публичного ключа.
+func signTestIDToken(t *testing.T, priv *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"alg":"RS256","typ":"JWT","kid":%q}`, kid)))
+	payloadBytes, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(payloadBytes)
+
+	digest := sha256.Sum256([]byte(header + "." + payload))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return header + "." + payload + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDCLoginAndCallbackRoundTrip(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid := "test-key-1"
+	nEnc := base64.RawURLEncoding.EncodeToString(priv.PublicKey.N.Bytes())
+	eBytes := []byte{1, 0, 1} // 65537
+	eEnc := base64.RawURLEncoding.EncodeToString(eBytes)
+
+	var idToken string
+	const clientID = "test-client"
+
+	// Мок-сервер отдаёт discovery, token_endpoint и jwks_uri.
+	var mux *http.ServeMux
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { mux.ServeHTTP(w, r) }))
+	defer mock.Close()
+
+	mux = http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{
+			AuthorizationEndpoint: mock.URL + "/authorize",
+			TokenEndpoint:         mock.URL + "/token",
+			UserinfoEndpoint:      mock.URL + "/userinfo",
+			JWKSUri:               mock.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcTokenResponse{
+			AccessToken: "access-123",
+			IDToken:     idToken,
+			TokenType:   "Bearer",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwkSet{Keys: []jwk{{Kty: "RSA", Kid: kid, N: nEnc, E: eEnc}}})
+	})
+
+	idToken = signTestIDToken(t, priv, kid, map[string]any{
+		"sub":   "user-42",
+		"email": "user42@example.com",
+		"aud":   clientID,
+	})
+
+	os.Setenv("OIDC_ISSUER", mock.URL)
+	os.Setenv("OIDC_CLIENT_ID", clientID)
+	os.Setenv("OIDC_CLIENT_SECRET", "secret")
+	os.Setenv("OIDC_REDIRECT_URL", "http://localhost/api/oidc/callback")
+	defer func() {
+		os.Unsetenv("OIDC_ISSUER")
+		os.Unsetenv("OIDC_CLIENT_ID")
+		os.Unsetenv("OIDC_CLIENT_SECRET")
+		os.Unsetenv("OIDC_REDIRECT_URL")
+	}()
+
+	// Шаг 1: /api/oidc/login должен выставить state cookie и вернуть редирект.
+	loginReq := httptest.NewRequest(http.MethodGet, "/api/oidc/login", nil)
+	loginRR := httptest.NewRecorder()
+	handleOIDCLogin(loginRR, loginReq)
+
+	if loginRR.Code != http.StatusFound {
+		t.Fatalf("ожидали редирект на authorization_endpoint, получили %d", loginRR.Code)
+	}
+	var stateCookie *http.Cookie
+	for _, c := range loginRR.Result().Cookies() {
+		if c.Name == "oidc_state" {
+			stateCookie = c
+		}
+	}
+	if stateCookie == nil {
+		t.Fatal("ожидали cookie oidc_state")
+	}
+	state, ok := verifyState(stateCookie.Value)
+	if !ok {
+		t.Fatal("не удалось проверить подпись state cookie")
+	}
+
+	// Шаг 2: /api/oidc/callback меняет code на токен и проверяет id_token.
+	cbReq := httptest.NewRequest(http.MethodGet, "/api/oidc/callback?code=abc&state="+state, nil)
+	cbReq.AddCookie(stateCookie)
+	cbRR := httptest.NewRecorder()
+	handleOIDCCallback(cbRR, cbReq)
+
+	if cbRR.Code != http.StatusFound {
+		t.Fatalf("ожидали успешный редирект после callback, получили %d: %s", cbRR.Code, cbRR.Body.String())
+	}
+
+	var appToken string
+	for _, c := range cbRR.Result().Cookies() {
+		if c.Name == "token" {
+			appToken = c.Value
+		}
+	}
+	if appToken == "" {
+		t.Fatal("ожидали cookie token после успешного OIDC-входа")
+	}
+
+	sub, email, ok := validateOIDCJWT(appToken)
+	if !ok {
+		t.Fatal("выданный токен должен проходить validateOIDCJWT")
+	}
+	if sub != "user-42" || email != "user42@example.com" {
+		t.Errorf("ожидали sub=user-42 email=user42@example.com, получили sub=%s email=%s", sub, email)
+	}
+}
+
+func TestHandleOIDCLoginNotConfigured(t *testing.T) {
+	os.Unsetenv("OIDC_ISSUER")
+	req := httptest.NewRequest(http.MethodGet, "/api/oidc/login", nil)
+	rr := httptest.NewRecorder()
+	handleOIDCLogin(rr, req)
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("ожидали 501 при не настроенном OIDC, получили %d", rr.Code)
+	}
+}
+
+// TestOIDCSubDoesNotCollideWithLocalUserID защищает от регрессии: sub
+// внешнего провайдера, совпадающий по написанию с чьим-то user_id (как
+// "1"), не должен давать auth() основания считать сессию сессией этого
+// локального пользователя — см. oidcSubPrefix в main.go.
+func TestOIDCSubDoesNotCollideWithLocalUserID(t *testing.T) {
+	os.Setenv("TODO_PASSWORD", "1234")
+	defer os.Unsetenv("TODO_PASSWORD")
+
+	token, err := makeOIDCJWT("1", "attacker@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotID int64
+	var gotScoped bool
+	handler := auth(func(w http.ResponseWriter, r *http.Request) {
+		gotID, gotScoped = scopedUserID(r)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ожидали, что токен с OIDC-sub=1 пройдёт auth(), код ответа %d", rr.Code)
+	}
+	if gotScoped && gotID == 1 {
+		t.Error("OIDC-sub=1 не должен давать scopedUserID id=1 — коллизия с локальным user_id=1")
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"database/sql"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Параметры анти-брутфорс защиты /api/signin: после maxFailedAttempts
+// неудачных попыток в пределах attemptWindow включаем экспоненциально
+// растущую блокировку (1с, 2с, 4с, ... до lockoutCap).
+const (
+	maxFailedAttempts = 5
+	attemptWindow     = 15 * time.Minute
+	baseLockout       = 1 * time.Second
+	lockoutCap        = 5 * time.Minute
+)
+
+// loginAttempt — счётчик неудачных попыток для одного ключа (IP или
+// логин). windowStart используется, чтобы сбросить счётчик, если с
+// последней попытки прошло больше attemptWindow.
+type loginAttempt struct {
+	count       int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+// loginThrottle — троттлинг попыток входа: map+mutex в памяти (как и
+// jtiLRUCache в blacklist.go) плюс персистентность в SQLite, чтобы
+// перезапуск сервера не обнулял счётчики атакующему в руки.
+type loginThrottle struct {
+	mu       sync.Mutex
+	db       *sql.DB
+	attempts map[string]*loginAttempt
+}
+
+func createLoginThrottleTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS login_throttle (
+			key VARCHAR(255) PRIMARY KEY,
+			fail_count INTEGER NOT NULL,
+			window_start INTEGER NOT NULL,
+			locked_until INTEGER NOT NULL
+		);`)
+	return err
+}
+
+func createLoginAuditTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS login_audit (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ip VARCHAR(64) NOT NULL,
+			login VARCHAR(255),
+			success INTEGER NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);`)
+	return err
+}
+
+func newLoginThrottle(db *sql.DB) *loginThrottle {
+	return &loginThrottle{db: db, attempts: make(map[string]*loginAttempt)}
+}
+
+// load достаёт сохранённый счётчик ключа из БД, если он ещё не в памяти —
+// нужен только после перезапуска процесса.
+func (lt *loginThrottle) load(key string) *loginAttempt {
+	if a, ok := lt.attempts[key]; ok {
+		return a
+	}
+	a := &loginAttempt{}
+	row := lt.db.QueryRow(`SELECT fail_count, window_start, locked_until FROM login_throttle WHERE key = ?`, key)
+	var windowStart, lockedUntil int64
+	if err := row.Scan(&a.count, &windowStart, &lockedUntil); err == nil {
+		a.windowStart = time.Unix(windowStart, 0)
+		a.lockedUntil = time.Unix(lockedUntil, 0)
+	}
+	lt.attempts[key] = a
+	return a
+}
+
+func (lt *loginThrottle) persist(key string, a *loginAttempt) error {
+	_, err := lt.db.Exec(`
+		INSERT OR REPLACE INTO login_throttle (key, fail_count, window_start, locked_until)
+		VALUES (?, ?, ?, ?)`,
+		key, a.count, a.windowStart.Unix(), a.lockedUntil.Unix())
+	return err
+}
+
+// Locked сообщает, заблокирован ли ключ на момент now, и сколько ещё
+// ждать, если да.
+func (lt *loginThrottle) Locked(key string, now time.Time) (locked bool, retryAfter time.Duration) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	a := lt.load(key)
+	if now.Before(a.lockedUntil) {
+		return true, a.lockedUntil.Sub(now)
+	}
+	return false, 0
+}
+
+// RecordFailure увеличивает счётчик неудачных попыток для ключа и, после
+// maxFailedAttempts, включает экспоненциально растущую блокировку
+// (1с, 2с, 4с, ... до lockoutCap).
+func (lt *loginThrottle) RecordFailure(key string, now time.Time) error {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	a := lt.load(key)
+	if a.windowStart.IsZero() || now.Sub(a.windowStart) > attemptWindow {
+		a.windowStart = now
+		a.count = 0
+	}
+	a.count++
+
+	if a.count >= maxFailedAttempts {
+		lockout := baseLockout << (a.count - maxFailedAttempts)
+		if lockout > lockoutCap || lockout <= 0 {
+			lockout = lockoutCap
+		}
+		a.lockedUntil = now.Add(lockout)
+	}
+
+	return lt.persist(key, a)
+}
+
+// RecordSuccess сбрасывает счётчик ключа — успешный вход снимает
+// блокировку.
+func (lt *loginThrottle) RecordSuccess(key string, now time.Time) error {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	a := &loginAttempt{windowStart: now}
+	lt.attempts[key] = a
+	return lt.persist(key, a)
+}
+
+// logLoginAttempt пишет запись о попытке входа в login_audit — для
+// последующего разбора (кто и когда подбирал пароль).
+func logLoginAttempt(db *sql.DB, ip, login string, success bool) error {
+	successInt := 0
+	if success {
+		successInt = 1
+	}
+	_, err := db.Exec(`INSERT INTO login_audit (ip, login, success) VALUES (?, ?, ?)`, ip, login, successInt)
+	return err
+}
+
+// clientIP достаёт IP-адрес из r.RemoteAddr (без порта) — этого достаточно
+// для троттлинга за одним процессом, за полноценный X-Forwarded-For
+// парсинг для прокси здесь не беремся.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// currentLoginThrottle — глобальный экземпляр, инициализируемый в main(),
+// по аналогии с currentBlacklist (blacklist.go) и keyStore (jwt.go).
+var (
+	loginThrottleMu      sync.RWMutex
+	currentLoginThrottle *loginThrottle
+)
+
+func setLoginThrottle(lt *loginThrottle) {
+	loginThrottleMu.Lock()
+	defer loginThrottleMu.Unlock()
+	currentLoginThrottle = lt
+}
+
+func getLoginThrottle() *loginThrottle {
+	loginThrottleMu.RLock()
+	defer loginThrottleMu.RUnlock()
+	return currentLoginThrottle
+}
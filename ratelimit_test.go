@@ -0,0 +1,140 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestLoginThrottle(t *testing.T) *loginThrottle {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := createLoginThrottleTable(db); err != nil {
+		t.Fatal(err)
+	}
+	return newLoginThrottle(db)
+}
+
+func TestLoginThrottleLocksAfterMaxFailedAttempts(t *testing.T) {
+	lt := newTestLoginThrottle(t)
+	now := time.Now()
+
+	for i := 0; i < maxFailedAttempts; i++ {
+		if locked, _ := lt.Locked("ip:1.2.3.4", now); locked {
+			t.Fatalf("не ожидали блокировку до %d-й попытки", maxFailedAttempts)
+		}
+		if err := lt.RecordFailure("ip:1.2.3.4", now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	locked, retryAfter := lt.Locked("ip:1.2.3.4", now)
+	if !locked {
+		t.Fatal("после превышения лимита попыток ключ должен быть заблокирован")
+	}
+	if retryAfter <= 0 {
+		t.Error("ожидали положительное время ожидания")
+	}
+}
+
+func TestLoginThrottleUnlocksAfterLockoutElapses(t *testing.T) {
+	lt := newTestLoginThrottle(t)
+	now := time.Now()
+
+	for i := 0; i < maxFailedAttempts+1; i++ {
+		if err := lt.RecordFailure("ip:1.2.3.4", now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	locked, retryAfter := lt.Locked("ip:1.2.3.4", now)
+	if !locked {
+		t.Fatal("ожидали блокировку сразу после превышения лимита")
+	}
+
+	later := now.Add(retryAfter + time.Second)
+	if locked, _ := lt.Locked("ip:1.2.3.4", later); locked {
+		t.Error("блокировка должна сняться после истечения retryAfter")
+	}
+}
+
+func TestLoginThrottleSuccessResetsCounter(t *testing.T) {
+	lt := newTestLoginThrottle(t)
+	now := time.Now()
+
+	for i := 0; i < maxFailedAttempts; i++ {
+		if err := lt.RecordFailure("login:alice", now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := lt.RecordSuccess("login:alice", now); err != nil {
+		t.Fatal(err)
+	}
+
+	// После сброса снова нужно maxFailedAttempts попыток до блокировки
+	for i := 0; i < maxFailedAttempts; i++ {
+		if locked, _ := lt.Locked("login:alice", now); locked {
+			t.Fatalf("не ожидали блокировку сразу после успешного входа (попытка %d)", i)
+		}
+		if err := lt.RecordFailure("login:alice", now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if locked, _ := lt.Locked("login:alice", now); !locked {
+		t.Error("ожидали блокировку после повторного исчерпания лимита")
+	}
+}
+
+// TestHandleSigninRateLimited проверяет сквозной сценарий: быстрый подбор
+// общего пароля упирается в 429 с Retry-After, а не в бесконечные 401.
+func TestHandleSigninRateLimited(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := createUsersTable(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := createLoginAuditTable(db); err != nil {
+		t.Fatal(err)
+	}
+
+	lt := newTestLoginThrottle(t)
+	setLoginThrottle(lt)
+	defer setLoginThrottle(nil)
+
+	t.Setenv("TODO_PASSWORD", "1234")
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleSignin(w, r, db)
+	})
+
+	doAttempt := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/signin", strings.NewReader(`{"password":"wrong"}`))
+		req.RemoteAddr = "9.9.9.9:12345"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	var last *httptest.ResponseRecorder
+	for i := 0; i < maxFailedAttempts+1; i++ {
+		last = doAttempt()
+	}
+
+	if last.Code != http.StatusTooManyRequests {
+		t.Fatalf("ожидали 429 после %d неудачных попыток, получили %d", maxFailedAttempts+1, last.Code)
+	}
+	if last.Header().Get("Retry-After") == "" {
+		t.Error("ожидали заголовок Retry-After в ответе 429")
+	}
+}
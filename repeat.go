@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRepeatSearchDays ограничивает сверху перебор дат для правил, у
+// которых нет готовой формулы "сдвинуть на N" (w, m, every N weeks on) —
+// без этого неудовлетворимое правило (например, "m 31 2" — 31 февраля
+// не бывает) зациклило бы NextDate навсегда.
+const maxRepeatSearchDays = 4 * 366
+
+// isoWeekday переводит time.Weekday (Sunday=0..Saturday=6) в ISO-день
+// недели (Monday=1..Sunday=7), как используется в правиле "w".
+func isoWeekday(t time.Time) int {
+	wd := int(t.Weekday())
+	if wd == 0 {
+		return 7
+	}
+	return wd
+}
+
+// weekRule — правило "w <1-7 через запятую>": повторять в указанные дни
+// недели (ISO, Mon=1..Sun=7).
+type weekRule struct {
+	weekdays map[int]bool
+}
+
+func parseWeekdayList(s string) (map[int]bool, error) {
+	parts := strings.Split(s, ",")
+	days := make(map[int]bool, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		d, err := strconv.Atoi(p)
+		if err != nil || d < 1 || d > 7 {
+			return nil, fmt.Errorf("недопустимый день недели: %q", p)
+		}
+		days[d] = true
+	}
+	if len(days) == 0 {
+		return nil, fmt.Errorf("пустой список дней недели")
+	}
+	return days, nil
+}
+
+func parseWeekRule(repeat string) (weekRule, error) {
+	arg := strings.TrimSpace(strings.TrimPrefix(repeat, "w "))
+	days, err := parseWeekdayList(arg)
+	if err != nil {
+		return weekRule{}, err
+	}
+	return weekRule{weekdays: days}, nil
+}
+
+func (rule weekRule) matches(t time.Time) bool {
+	return rule.weekdays[isoWeekday(t)]
+}
+
+// monthRule — правило "m <дни> [месяцы]": повторять в указанные дни
+// месяца (1..31, либо -1/-2 — последний/предпоследний день месяца),
+// опционально ограниченное списком месяцев (1..12).
+type monthRule struct {
+	days   []int // может содержать -1, -2
+	months map[int]bool
+}
+
+func parseMonthRule(repeat string) (monthRule, error) {
+	arg := strings.TrimSpace(strings.TrimPrefix(repeat, "m "))
+	if arg == "" {
+		return monthRule{}, fmt.Errorf("не указаны дни месяца")
+	}
+	fields := strings.Fields(arg)
+
+	var daysField string
+	var monthsField string
+	switch len(fields) {
+	case 1:
+		daysField = fields[0]
+	case 2:
+		daysField = fields[0]
+		monthsField = fields[1]
+	default:
+		return monthRule{}, fmt.Errorf("недопустимый формат правила месяца: %q", repeat)
+	}
+
+	var days []int
+	for _, p := range strings.Split(daysField, ",") {
+		p = strings.TrimSpace(p)
+		d, err := strconv.Atoi(p)
+		if err != nil || d == 0 || d < -2 || d > 31 {
+			return monthRule{}, fmt.Errorf("недопустимый день месяца: %q", p)
+		}
+		days = append(days, d)
+	}
+
+	var months map[int]bool
+	if monthsField != "" {
+		months = make(map[int]bool)
+		for _, p := range strings.Split(monthsField, ",") {
+			p = strings.TrimSpace(p)
+			m, err := strconv.Atoi(p)
+			if err != nil || m < 1 || m > 12 {
+				return monthRule{}, fmt.Errorf("недопустимый месяц: %q", p)
+			}
+			months[m] = true
+		}
+	}
+
+	return monthRule{days: days, months: months}, nil
+}
+
+func lastDayOfMonth(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+}
+
+func (rule monthRule) matches(t time.Time) bool {
+	if rule.months != nil && !rule.months[int(t.Month())] {
+		return false
+	}
+	last := lastDayOfMonth(t)
+	for _, d := range rule.days {
+		switch {
+		case d == -1 && t.Day() == last:
+			return true
+		case d == -2 && t.Day() == last-1:
+			return true
+		case d > 0 && t.Day() == d:
+			return true
+		}
+	}
+	return false
+}
+
+// everyWeeksRule — составное правило "every N weeks on <дни недели>".
+type everyWeeksRule struct {
+	interval int
+	weekdays map[int]bool
+}
+
+// parseEveryWeeksRule разбирает "every N weeks on 1,3,5".
+func parseEveryWeeksRule(repeat string) (everyWeeksRule, error) {
+	fields := strings.Fields(repeat)
+	if len(fields) != 5 || fields[0] != "every" || fields[2] != "weeks" || fields[3] != "on" {
+		return everyWeeksRule{}, fmt.Errorf("недопустимый формат составного правила: %q", repeat)
+	}
+	n, err := strconv.Atoi(fields[1])
+	if err != nil || n <= 0 {
+		return everyWeeksRule{}, fmt.Errorf("недопустимый интервал недель: %q", fields[1])
+	}
+	days, err := parseWeekdayList(fields[4])
+	if err != nil {
+		return everyWeeksRule{}, err
+	}
+	return everyWeeksRule{interval: n, weekdays: days}, nil
+}
+
+// isoWeekStart — понедельник 00:00 недели, которой принадлежит t.
+func isoWeekStart(t time.Time) time.Time {
+	d := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return d.AddDate(0, 0, -(isoWeekday(d) - 1))
+}
+
+func (rule everyWeeksRule) matches(anchor, t time.Time) bool {
+	if !rule.weekdays[isoWeekday(t)] {
+		return false
+	}
+	weeksDiff := int(isoWeekStart(t).Sub(isoWeekStart(anchor)).Hours() / 24 / 7)
+	return weeksDiff >= 0 && weeksDiff%rule.interval == 0
+}
+
+// searchNextMatch ищет первую дату после start (включительно), для
+// которой matches возвращает true, перебирая дни вплоть до
+// maxRepeatSearchDays — страховка от неудовлетворимых правил (например,
+// "m 31 2").
+func searchNextMatch(start time.Time, matches func(time.Time) bool) (string, error) {
+	candidate := start
+	for i := 0; i < maxRepeatSearchDays; i++ {
+		if matches(candidate) {
+			return candidate.Format(DateFormat), nil
+		}
+		candidate = candidate.AddDate(0, 0, 1)
+	}
+	return "", fmt.Errorf("не удалось подобрать дату по правилу повторения за %d дней", maxRepeatSearchDays)
+}
+
+// repeatSearchStart — точка, с которой начинается day-by-day перебор для
+// правил w/m/every: max(taskDate, now)+1 день, обе даты без времени суток.
+func repeatSearchStart(taskDate, now time.Time) time.Time {
+	taskDay := time.Date(taskDate.Year(), taskDate.Month(), taskDate.Day(), 0, 0, 0, 0, taskDate.Location())
+	nowDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	start := taskDay
+	if nowDay.After(start) {
+		start = nowDay
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// repeatTerminator — опциональное ограничение серии повторений компактной
+// грамматики: граница по дате (UNTIL) либо по числу повторений (COUNT).
+// Нулевое значение repeatTerminator означает "терминатор не задан".
+type repeatTerminator struct {
+	until time.Time
+	count int
+}
+
+// splitRepeatTerminator отделяет от repeat необязательный суффикс "until
+// <дата>" или "count <N>" — последние два поля строки. Это компактный
+// аналог терминаторов UNTIL/COUNT из RFC 5545 (ErrSeriesEnded, см.
+// rrule.go), но для грамматики d/y/w/m/every, где они не встроены в само
+// правило. Если суффикса нет, base возвращается как есть.
+func splitRepeatTerminator(repeat string) (base string, term repeatTerminator, err error) {
+	fields := strings.Fields(repeat)
+	if len(fields) < 2 {
+		return repeat, repeatTerminator{}, nil
+	}
+	last := len(fields) - 1
+	switch fields[last-1] {
+	case "until":
+		until, err := ParseTaskDate(fields[last])
+		if err != nil {
+			return "", repeatTerminator{}, fmt.Errorf("недопустимая граница until: %q", fields[last])
+		}
+		return strings.Join(fields[:last-1], " "), repeatTerminator{until: until}, nil
+	case "count":
+		n, err := strconv.Atoi(fields[last])
+		if err != nil || n <= 0 {
+			return "", repeatTerminator{}, fmt.Errorf("недопустимое значение count: %q", fields[last])
+		}
+		return strings.Join(fields[:last-1], " "), repeatTerminator{count: n}, nil
+	default:
+		return repeat, repeatTerminator{}, nil
+	}
+}
+
+// countOccurrences возвращает номер occurrence'а (1 — сама исходная
+// original) для target в серии repeat. Используется терминатором COUNT:
+// шагаем вперёд через computeNextOccurrence, которая для любого правила
+// компактной грамматики умеет находить "следующее вхождение после X".
+func countOccurrences(original, target time.Time, repeat string) (int, error) {
+	count := 1
+	cur := original
+	for i := 0; i < maxRepeatSearchDays; i++ {
+		if cur.Equal(target) {
+			return count, nil
+		}
+		nextStr, err := computeNextOccurrence(cur, cur, repeat)
+		if err != nil {
+			return 0, err
+		}
+		next, err := ParseTaskDate(nextStr)
+		if err != nil {
+			return 0, err
+		}
+		cur = next.In(original.Location())
+		count++
+	}
+	return 0, fmt.Errorf("не удалось подсчитать номер повторения для %s по правилу %q", target.Format(DateFormat), repeat)
+}
+
+// validateRepeatRule проверяет, что repeat — это известное правило
+// повторения (компактная грамматика d/y/w/m/every, опционально с
+// терминатором until/count, или RRULE, см. rrule.go), не вычисляя
+// следующую дату. Используется в handleUpdateTask, чтобы не дублировать
+// парсинг, которым и так занимается NextDate.
+func validateRepeatRule(repeat string) error {
+	if isRRule(repeat) {
+		_, err := parseRRule(repeat)
+		return err
+	}
+
+	repeat, _, err := splitRepeatTerminator(repeat)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case strings.HasPrefix(repeat, "d "):
+		daysStr := strings.TrimSpace(strings.TrimPrefix(repeat, "d "))
+		days, err := strconv.Atoi(daysStr)
+		if err != nil || days <= 0 {
+			return fmt.Errorf("недопустимый интервал дней: %q", daysStr)
+		}
+		return nil
+	case repeat == "y":
+		return nil
+	case strings.HasPrefix(repeat, "w "):
+		_, err := parseWeekRule(repeat)
+		return err
+	case strings.HasPrefix(repeat, "m "):
+		_, err := parseMonthRule(repeat)
+		return err
+	case strings.HasPrefix(repeat, "every "):
+		_, err := parseEveryWeeksRule(repeat)
+		return err
+	default:
+		return fmt.Errorf("неподдерживаемый формат: %q", repeat)
+	}
+}
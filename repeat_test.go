@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNextDateWeekRule(t *testing.T) {
+	tests := []struct {
+		now      string
+		date     string
+		repeat   string
+		expected string
+		hasError bool
+	}{
+		// 2026-07-26 — воскресенье (ISO 7). Следующая среда (3) — 29 июля.
+		{"20260726", "20260101", "w 3", "20260729", false},
+		// Сразу несколько дней недели — берём ближайший после now.
+		{"20260726", "20260101", "w 1,3,5", "20260727", false},
+		{"20260726", "20260101", "w 8", "", true}, // недопустимый день недели
+	}
+
+	for _, tt := range tests {
+		now, _ := time.Parse(DateFormat, tt.now)
+		got, err := NextDate(now, tt.date, tt.repeat)
+		if tt.hasError {
+			if err == nil {
+				t.Errorf("NextDate(%s, %s, %q): ожидали ошибку", tt.now, tt.date, tt.repeat)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NextDate(%s, %s, %q): неожиданная ошибка: %v", tt.now, tt.date, tt.repeat, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("NextDate(%s, %s, %q) = %s, ожидали %s", tt.now, tt.date, tt.repeat, got, tt.expected)
+		}
+	}
+}
+
+func TestNextDateMonthRule(t *testing.T) {
+	tests := []struct {
+		now      string
+		date     string
+		repeat   string
+		expected string
+		hasError bool
+	}{
+		{"20260115", "20260101", "m 20", "20260120", false},
+		// -1 => последний день месяца; после 20260126 следующий — 31 июля.
+		{"20260726", "20260101", "m -1", "20260731", false},
+		// Ограничение по месяцам: 1 число, только март/июнь/сентябрь/декабрь.
+		{"20260726", "20260101", "m 1 3,6,9,12", "20260901", false},
+		{"20260115", "20260101", "m 32", "", true}, // недопустимый день месяца
+	}
+
+	for _, tt := range tests {
+		now, _ := time.Parse(DateFormat, tt.now)
+		got, err := NextDate(now, tt.date, tt.repeat)
+		if tt.hasError {
+			if err == nil {
+				t.Errorf("NextDate(%s, %s, %q): ожидали ошибку", tt.now, tt.date, tt.repeat)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NextDate(%s, %s, %q): неожиданная ошибка: %v", tt.now, tt.date, tt.repeat, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("NextDate(%s, %s, %q) = %s, ожидали %s", tt.now, tt.date, tt.repeat, got, tt.expected)
+		}
+	}
+}
+
+func TestNextDateEveryWeeksRule(t *testing.T) {
+	// Задача привязана к понедельнику 2026-01-05; правило — каждые 2 недели
+	// по понедельникам. Неделя 2026-07-27 (первый понедельник после now)
+	// отстоит от неё на нечётное число недель (29), так что ближайшая
+	// подходящая — следующий понедельник, 2026-08-03 (30 недель).
+	now, _ := time.Parse(DateFormat, "20260726")
+	got, err := NextDate(now, "20260105", "every 2 weeks on 1")
+	if err != nil {
+		t.Fatalf("NextDate вернул ошибку: %v", err)
+	}
+	if got != "20260803" {
+		t.Errorf("NextDate = %s, ожидали 20260803", got)
+	}
+
+	if _, err := NextDate(now, "20260105", "every 0 weeks on 1"); err == nil {
+		t.Error("ожидали ошибку при интервале 0")
+	}
+	if _, err := NextDate(now, "20260105", "every 2 months on 1"); err == nil {
+		t.Error("ожидали ошибку для неверного ключевого слова")
+	}
+}
+
+func TestValidateRepeatRule(t *testing.T) {
+	valid := []string{
+		"d 1", "y", "w 1,7", "m 1,15,-1", "m 1 3,6", "every 3 weeks on 2,4",
+		"d 7 until 20261231", "w 1,3,5 count 12",
+	}
+	for _, r := range valid {
+		if err := validateRepeatRule(r); err != nil {
+			t.Errorf("validateRepeatRule(%q) вернул ошибку: %v", r, err)
+		}
+	}
+
+	invalid := []string{
+		"", "x", "w 0", "m 0", "every 1 weeks", "every abc weeks on 1",
+		"d 7 until abc", "d 7 count 0",
+	}
+	for _, r := range invalid {
+		if err := validateRepeatRule(r); err == nil {
+			t.Errorf("validateRepeatRule(%q): ожидали ошибку", r)
+		}
+	}
+}
+
+func TestNextDateUntilTerminator(t *testing.T) {
+	// Серия "d 7" с границей until 20260115: 20260108 ещё укладывается,
+	// а 20260115 — уже за её пределами.
+	now, _ := time.Parse(DateFormat, "20260102")
+	got, err := NextDate(now, "20260101", "d 7 until 20260115")
+	if err != nil {
+		t.Fatalf("NextDate вернул ошибку: %v", err)
+	}
+	if got != "20260108" {
+		t.Errorf("NextDate = %s, ожидали 20260108", got)
+	}
+
+	now, _ = time.Parse(DateFormat, "20260110")
+	_, err = NextDate(now, "20260101", "d 7 until 20260112")
+	if !errors.Is(err, ErrSeriesEnded) {
+		t.Errorf("ожидали ErrSeriesEnded, получили %v", err)
+	}
+}
+
+func TestNextDateCountTerminator(t *testing.T) {
+	// 20260105 — понедельник. Occurrence 1 = 20260105 (сама дата задачи),
+	// occurrence 2 = ближайшая следующая среда/пятница/понедельник после
+	// now — 20260107 (среда).
+	now, _ := time.Parse(DateFormat, "20260106")
+
+	got, err := NextDate(now, "20260105", "w 1,3,5 count 3")
+	if err != nil {
+		t.Fatalf("NextDate вернул ошибку: %v", err)
+	}
+	if got != "20260107" {
+		t.Errorf("NextDate = %s, ожидали 20260107", got)
+	}
+
+	_, err = NextDate(now, "20260105", "w 1,3,5 count 1")
+	if !errors.Is(err, ErrSeriesEnded) {
+		t.Errorf("ожидали ErrSeriesEnded при превышении count, получили %v", err)
+	}
+}
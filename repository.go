@@ -0,0 +1,188 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// TaskRepository собирает весь доступ к таблице scheduler в одном месте:
+// раньше db.Exec/db.QueryRow были разбросаны по каждому обработчику
+// (handleAddTask, handleGetTask и т. д.), из-за чего замена SQLite на
+// другую СУБД или модульное тестирование обработчиков без реальной БД
+// требовали править сразу все файлы. Методы интерфейса называются по
+// действию, а не зеркалят SQL — handleGetTasks по-прежнему сам решает,
+// какой из List-методов вызвать в зависимости от параметра search.
+type TaskRepository interface {
+	Insert(title string, date Date, comment, repeat string, userID int64, scoped bool) (int64, error)
+	Get(id int, userID int64, scoped bool) (Task, error)
+	Update(t Task, userID int64, scoped bool) (rowsAffected int64, err error)
+	Delete(id int, userID int64, scoped bool) (rowsAffected int64, err error)
+	ListRecent(limit int, userID int64, scoped bool) ([]Task, error)
+	ListByDate(date string, limit int, userID int64, scoped bool) ([]Task, error)
+	ListByText(pattern string, limit int, userID int64, scoped bool) ([]Task, error)
+	ListAll(userID int64, scoped bool) ([]Task, error)
+}
+
+// sqlTaskRepository — реализация TaskRepository поверх database/sql.
+// Один и тот же код работает с SQLite и Postgres: единственное различие
+// между ними — синтаксис плейсхолдеров, который bind() подставляет в
+// зависимости от driver (см. dbdriver.go).
+type sqlTaskRepository struct {
+	db     *sql.DB
+	driver string
+}
+
+// NewTaskRepository создаёт TaskRepository для уже открытого соединения.
+// driver — одна из констант dbDriver* (dbdriver.go), определяющая
+// диалект плейсхолдеров.
+func NewTaskRepository(db *sql.DB, driver string) TaskRepository {
+	return &sqlTaskRepository{db: db, driver: driver}
+}
+
+// bind переводит запросы, написанные с плейсхолдерами "?" (как их и
+// раньше писали обработчики под SQLite), в синтаксис "$1, $2, ..."
+// для Postgres. Для SQLite и MySQL запрос возвращается как есть — оба
+// диалекта понимают "?".
+func bind(query string, driver string) string {
+	if driver != dbDriverPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (repo *sqlTaskRepository) Insert(title string, date Date, comment, repeat string, userID int64, scoped bool) (int64, error) {
+	var userIDArg any
+	if scoped {
+		userIDArg = userID
+	}
+	res, err := dbExec(repo.db, "scheduler_insert", bind(`
+		INSERT INTO scheduler (title, date, comment, repeat, user_id)
+		VALUES (?, ?, ?, ?, ?)`, repo.driver),
+		title, date, comment, repeat, userIDArg)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (repo *sqlTaskRepository) Get(id int, userID int64, scoped bool) (Task, error) {
+	query := `SELECT id, date, title, comment, repeat FROM scheduler WHERE id = ?`
+	args := []any{id}
+	if scoped {
+		query += " AND user_id = ?"
+		args = append(args, userID)
+	}
+
+	var t Task
+	err := dbQueryRow(repo.db, "scheduler_get", bind(query, repo.driver), args...).
+		Scan(&t.ID, &t.Date, &t.Title, &t.Comment, &t.Repeat)
+	return t, err
+}
+
+func (repo *sqlTaskRepository) Update(t Task, userID int64, scoped bool) (int64, error) {
+	query := `UPDATE scheduler SET title=?, date=?, comment=?, repeat=? WHERE id=?`
+	args := []any{t.Title, t.Date, t.Comment, t.Repeat, t.ID}
+	if scoped {
+		query += " AND user_id=?"
+		args = append(args, userID)
+	}
+
+	res, err := dbExec(repo.db, "scheduler_update", bind(query, repo.driver), args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (repo *sqlTaskRepository) Delete(id int, userID int64, scoped bool) (int64, error) {
+	query := "DELETE FROM scheduler WHERE id = ?"
+	args := []any{id}
+	if scoped {
+		query += " AND user_id = ?"
+		args = append(args, userID)
+	}
+
+	res, err := dbExec(repo.db, "scheduler_delete", bind(query, repo.driver), args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (repo *sqlTaskRepository) ListRecent(limit int, userID int64, scoped bool) ([]Task, error) {
+	query := `SELECT id, date, title, comment, repeat FROM scheduler WHERE 1 = 1`
+	var args []any
+	if scoped {
+		query += " AND user_id = ?"
+		args = append(args, userID)
+	}
+	query += " ORDER BY date ASC LIMIT ?"
+	args = append(args, limit)
+	return repo.query("scheduler_list_recent", query, args...)
+}
+
+func (repo *sqlTaskRepository) ListByDate(date string, limit int, userID int64, scoped bool) ([]Task, error) {
+	query := `SELECT id, date, title, comment, repeat FROM scheduler WHERE date = ?`
+	args := []any{date}
+	if scoped {
+		query += " AND user_id = ?"
+		args = append(args, userID)
+	}
+	query += " ORDER BY date ASC LIMIT ?"
+	args = append(args, limit)
+	return repo.query("scheduler_list_by_date", query, args...)
+}
+
+func (repo *sqlTaskRepository) ListByText(pattern string, limit int, userID int64, scoped bool) ([]Task, error) {
+	query := `SELECT id, date, title, comment, repeat FROM scheduler WHERE (title LIKE ? OR comment LIKE ?)`
+	args := []any{pattern, pattern}
+	if scoped {
+		query += " AND user_id = ?"
+		args = append(args, userID)
+	}
+	query += " ORDER BY date ASC LIMIT ?"
+	args = append(args, limit)
+	return repo.query("scheduler_list_by_text", query, args...)
+}
+
+// ListAll отдаёт все задачи без LIMIT — нужен календарному фиду (ical.go),
+// которому, в отличие от handleGetTasks, нельзя обрезать список.
+func (repo *sqlTaskRepository) ListAll(userID int64, scoped bool) ([]Task, error) {
+	query := `SELECT id, date, title, comment, repeat FROM scheduler WHERE 1 = 1`
+	var args []any
+	if scoped {
+		query += " AND user_id = ?"
+		args = append(args, userID)
+	}
+	query += " ORDER BY date ASC"
+	return repo.query("scheduler_list_all", query, args...)
+}
+
+func (repo *sqlTaskRepository) query(operation, query string, args ...any) ([]Task, error) {
+	rows, err := dbQuery(repo.db, operation, bind(query, repo.driver), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []Task
+	for rows.Next() {
+		var t Task
+		if err := rows.Scan(&t.ID, &t.Date, &t.Title, &t.Comment, &t.Repeat); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func setupRepoTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := createSchedulerTable(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateSchedulerUserID(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSQLTaskRepositoryInsertGetUpdateDelete(t *testing.T) {
+	db := setupRepoTestDB(t)
+	repo := NewTaskRepository(db, dbDriverSQLite)
+
+	date, err := ParseDate("20260101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := repo.Insert("Задача", date, "коммент", "", 0, false)
+	if err != nil {
+		t.Fatalf("Insert вернул ошибку: %v", err)
+	}
+
+	task, err := repo.Get(int(id), 0, false)
+	if err != nil {
+		t.Fatalf("Get вернул ошибку: %v", err)
+	}
+	if task.Title != "Задача" || task.Date.String() != "20260101" {
+		t.Errorf("Get вернул неожиданную задачу: %+v", task)
+	}
+
+	task.Date, err = ParseDate("20260102")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rowsAffected, err := repo.Update(task, 0, false)
+	if err != nil {
+		t.Fatalf("Update вернул ошибку: %v", err)
+	}
+	if rowsAffected != 1 {
+		t.Errorf("ожидали 1 изменённую строку, получили %d", rowsAffected)
+	}
+
+	rowsAffected, err = repo.Delete(int(id), 0, false)
+	if err != nil {
+		t.Fatalf("Delete вернул ошибку: %v", err)
+	}
+	if rowsAffected != 1 {
+		t.Errorf("ожидали 1 удалённую строку, получили %d", rowsAffected)
+	}
+
+	if _, err := repo.Get(int(id), 0, false); err != sql.ErrNoRows {
+		t.Errorf("ожидали sql.ErrNoRows после удаления, получили %v", err)
+	}
+}
+
+func TestSQLTaskRepositoryScopingByUser(t *testing.T) {
+	db := setupRepoTestDB(t)
+	repo := NewTaskRepository(db, dbDriverSQLite)
+
+	date, err := ParseDate("20260101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Insert("Чужая задача", date, "", "", 42, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Insert("Моя задача", date, "", "", 7, true); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := repo.ListRecent(50, 7, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Title != "Моя задача" {
+		t.Errorf("ListRecent с user_id=7 должен вернуть только задачу этого пользователя, получили %+v", tasks)
+	}
+
+	all, err := repo.ListAll(0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Errorf("ListAll без scoped должен вернуть задачи всех пользователей, получили %d", len(all))
+	}
+}
+
+func TestBindTranslatesPlaceholdersForPostgres(t *testing.T) {
+	got := bind("SELECT * FROM scheduler WHERE id = ? AND user_id = ?", dbDriverPostgres)
+	want := "SELECT * FROM scheduler WHERE id = $1 AND user_id = $2"
+	if got != want {
+		t.Errorf("bind(postgres) = %q, ожидали %q", got, want)
+	}
+
+	got = bind("SELECT * FROM scheduler WHERE id = ?", dbDriverSQLite)
+	want = "SELECT * FROM scheduler WHERE id = ?"
+	if got != want {
+		t.Errorf("bind(sqlite3) не должен менять плейсхолдеры, получили %q", got)
+	}
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrSeriesEnded означает, что правило повторения исчерпано — следующее
+// по порядку вхождение приходится на дату позже UNTIL либо превышает
+// COUNT вхождений, отсчитываемых от исходной даты задачи.
+var ErrSeriesEnded = errors.New("серия повторений завершена")
+
+// maxRRuleOccurrences ограничивает перебор периодов (недель/месяцев/лет)
+// для RRULE — страховка от зацикливания на правилах, которые никогда не
+// дают вхождения (например, BYMONTHDAY=31 с BYMONTH=2).
+const maxRRuleOccurrences = 10000
+
+// rrule — разобранные части строки RFC 5545 RRULE, насколько они имеют
+// смысл для задач с точностью до дня (без BYHOUR/BYMINUTE/BYSECOND).
+type rrule struct {
+	freq       string // DAILY, WEEKLY, MONTHLY, YEARLY
+	interval   int
+	byMonth    map[int]bool
+	byMonthDay []int // может содержать отрицательные значения — "с конца месяца"
+	byDay      []byDayEntry
+	bySetPos   []int
+	count      int       // 0 = не ограничено
+	until      time.Time // нулевое значение = не ограничено
+	wkst       int       // ISO-день недели, которым считается начало недели
+}
+
+// byDayEntry — один элемент BYDAY, например "MO" или "-1SU" (последнее
+// воскресенье периода) или "2TU" (второй вторник периода).
+type byDayEntry struct {
+	ordinal int // 0, если порядковый номер не указан
+	weekday int // ISO 1..7
+}
+
+var isoWeekdayNames = map[string]int{
+	"MO": 1, "TU": 2, "WE": 3, "TH": 4, "FR": 5, "SA": 6, "SU": 7,
+}
+
+// isRRule определяет, что repeat — это строка RRULE, а не компактная
+// грамматика этого репозитория (d/y/w/m/every).
+func isRRule(repeat string) bool {
+	return strings.HasPrefix(repeat, "RRULE:") || strings.Contains(repeat, "FREQ=")
+}
+
+// parseRRule разбирает "RRULE:FREQ=...;..." или "FREQ=...;..." (без
+// префикса, как его кладут в .ics VEVENT) в rrule.
+func parseRRule(repeat string) (rrule, error) {
+	body := strings.TrimPrefix(repeat, "RRULE:")
+	rule := rrule{interval: 1, wkst: 1}
+	seenFreq := false
+
+	for _, part := range strings.Split(body, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return rrule{}, fmt.Errorf("недопустимая часть RRULE: %q", part)
+		}
+		key, val := strings.ToUpper(kv[0]), kv[1]
+		switch key {
+		case "FREQ":
+			switch val {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				rule.freq = val
+				seenFreq = true
+			default:
+				return rrule{}, fmt.Errorf("неподдерживаемая частота FREQ=%s", val)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n <= 0 {
+				return rrule{}, fmt.Errorf("недопустимый INTERVAL=%s", val)
+			}
+			rule.interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n <= 0 {
+				return rrule{}, fmt.Errorf("недопустимый COUNT=%s", val)
+			}
+			rule.count = n
+		case "UNTIL":
+			t, err := parseRRuleUntil(val)
+			if err != nil {
+				return rrule{}, err
+			}
+			rule.until = t
+		case "BYMONTH":
+			months := make(map[int]bool)
+			for _, p := range strings.Split(val, ",") {
+				m, err := strconv.Atoi(p)
+				if err != nil || m < 1 || m > 12 {
+					return rrule{}, fmt.Errorf("недопустимый BYMONTH=%s", p)
+				}
+				months[m] = true
+			}
+			rule.byMonth = months
+		case "BYMONTHDAY":
+			for _, p := range strings.Split(val, ",") {
+				d, err := strconv.Atoi(p)
+				if err != nil || d == 0 || d < -31 || d > 31 {
+					return rrule{}, fmt.Errorf("недопустимый BYMONTHDAY=%s", p)
+				}
+				rule.byMonthDay = append(rule.byMonthDay, d)
+			}
+		case "BYDAY":
+			for _, p := range strings.Split(val, ",") {
+				entry, err := parseByDayEntry(p)
+				if err != nil {
+					return rrule{}, err
+				}
+				rule.byDay = append(rule.byDay, entry)
+			}
+		case "BYSETPOS":
+			for _, p := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(p)
+				if err != nil || n == 0 {
+					return rrule{}, fmt.Errorf("недопустимый BYSETPOS=%s", p)
+				}
+				rule.bySetPos = append(rule.bySetPos, n)
+			}
+		case "WKST":
+			wd, ok := isoWeekdayNames[strings.ToUpper(val)]
+			if !ok {
+				return rrule{}, fmt.Errorf("недопустимый WKST=%s", val)
+			}
+			rule.wkst = wd
+		default:
+			// Остальные ключи RFC5545 (BYHOUR, BYMINUTE, BYSECOND и т.п.)
+			// не имеют смысла для задач с точностью до дня — не падаем,
+			// просто игнорируем их.
+		}
+	}
+
+	if !seenFreq {
+		return rrule{}, fmt.Errorf("в RRULE отсутствует FREQ: %q", repeat)
+	}
+	return rule, nil
+}
+
+func parseByDayEntry(s string) (byDayEntry, error) {
+	s = strings.TrimSpace(s)
+	i := 0
+	for i < len(s) && (s[i] == '-' || s[i] == '+' || (s[i] >= '0' && s[i] <= '9')) {
+		i++
+	}
+	ordinal := 0
+	if i > 0 {
+		n, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return byDayEntry{}, fmt.Errorf("недопустимый BYDAY=%s", s)
+		}
+		ordinal = n
+	}
+	wd, ok := isoWeekdayNames[strings.ToUpper(s[i:])]
+	if !ok {
+		return byDayEntry{}, fmt.Errorf("недопустимый день недели в BYDAY=%s", s)
+	}
+	return byDayEntry{ordinal: ordinal, weekday: wd}, nil
+}
+
+func parseRRuleUntil(s string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("недопустимый UNTIL=%s", s)
+}
+
+// weekStartWithWkst — начало недели (00:00), которой принадлежит t, при
+// условии что неделя начинается с дня недели wkst (ISO 1..7).
+func weekStartWithWkst(t time.Time, wkst int) time.Time {
+	d := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := (isoWeekday(d) - wkst + 7) % 7
+	return d.AddDate(0, 0, -offset)
+}
+
+// matchesOrdinalInMonth проверяет порядковый номер вхождения дня недели
+// в месяце — нужно для BYDAY вида "-1SU"/"2TU".
+func matchesOrdinalInMonth(day time.Time, ordinal, lastDay int) bool {
+	if ordinal == 0 {
+		return true
+	}
+	if ordinal > 0 {
+		return (day.Day()-1)/7+1 == ordinal
+	}
+	return (lastDay-day.Day())/7+1 == -ordinal
+}
+
+// monthCandidates — дни-кандидаты внутри месяца monthStart, отобранные
+// по BYMONTHDAY/BYDAY, либо (если оба не заданы) тот же день месяца, что
+// и в исходной задаче.
+func (rule rrule) monthCandidates(anchor, monthStart time.Time) []time.Time {
+	last := lastDayOfMonth(monthStart)
+
+	if len(rule.byMonthDay) > 0 {
+		days := make(map[int]bool, len(rule.byMonthDay))
+		for _, d := range rule.byMonthDay {
+			day := d
+			if day < 0 {
+				day = last + day + 1
+			}
+			if day >= 1 && day <= last {
+				days[day] = true
+			}
+		}
+		var out []time.Time
+		for d := 1; d <= last; d++ {
+			if days[d] {
+				out = append(out, time.Date(monthStart.Year(), monthStart.Month(), d, 0, 0, 0, 0, monthStart.Location()))
+			}
+		}
+		return out
+	}
+
+	if len(rule.byDay) > 0 {
+		var out []time.Time
+		for d := 1; d <= last; d++ {
+			day := time.Date(monthStart.Year(), monthStart.Month(), d, 0, 0, 0, 0, monthStart.Location())
+			for _, entry := range rule.byDay {
+				if isoWeekday(day) == entry.weekday && matchesOrdinalInMonth(day, entry.ordinal, last) {
+					out = append(out, day)
+					break
+				}
+			}
+		}
+		return out
+	}
+
+	day := anchor.Day()
+	if day > last {
+		day = last
+	}
+	return []time.Time{time.Date(monthStart.Year(), monthStart.Month(), day, 0, 0, 0, 0, monthStart.Location())}
+}
+
+// yearCandidates — дни-кандидаты внутри года yearStart. Без BYMONTH
+// берётся только месяц исходной задачи; без BYMONTHDAY/BYDAY внутри
+// месяца — тот же день, что в задаче, с откатом на 1 марта для 29
+// февраля в невисокосный год (как в компактном правиле "y").
+func (rule rrule) yearCandidates(anchor, yearStart time.Time) []time.Time {
+	months := []int{int(anchor.Month())}
+	if rule.byMonth != nil {
+		months = months[:0]
+		for m := 1; m <= 12; m++ {
+			if rule.byMonth[m] {
+				months = append(months, m)
+			}
+		}
+	}
+
+	var out []time.Time
+	for _, m := range months {
+		monthStart := time.Date(yearStart.Year(), time.Month(m), 1, 0, 0, 0, 0, yearStart.Location())
+		if len(rule.byMonthDay) > 0 || len(rule.byDay) > 0 {
+			out = append(out, rule.monthCandidates(anchor, monthStart)...)
+			continue
+		}
+		if anchor.Day() == 29 && m == 2 && !isLeapYear(yearStart.Year()) {
+			out = append(out, time.Date(yearStart.Year(), 3, 1, 0, 0, 0, 0, yearStart.Location()))
+			continue
+		}
+		last := lastDayOfMonth(monthStart)
+		day := anchor.Day()
+		if day > last {
+			day = last
+		}
+		out = append(out, time.Date(yearStart.Year(), time.Month(m), day, 0, 0, 0, 0, yearStart.Location()))
+	}
+	return out
+}
+
+// periodCandidates отдаёт отсортированные дни-кандидаты для периода,
+// начинающегося в periodStart (его длина и смысл зависят от FREQ), уже
+// отфильтрованные BYMONTH и прорежённые BYSETPOS.
+func (rule rrule) periodCandidates(anchor, periodStart time.Time) []time.Time {
+	var candidates []time.Time
+	switch rule.freq {
+	case "DAILY":
+		candidates = []time.Time{periodStart}
+	case "WEEKLY":
+		for d := 0; d < 7; d++ {
+			day := periodStart.AddDate(0, 0, d)
+			if len(rule.byDay) == 0 {
+				if isoWeekday(day) == isoWeekday(anchor) {
+					candidates = append(candidates, day)
+				}
+				continue
+			}
+			for _, entry := range rule.byDay {
+				if isoWeekday(day) == entry.weekday {
+					candidates = append(candidates, day)
+					break
+				}
+			}
+		}
+	case "MONTHLY":
+		candidates = rule.monthCandidates(anchor, periodStart)
+	case "YEARLY":
+		candidates = rule.yearCandidates(anchor, periodStart)
+	}
+
+	if rule.byMonth != nil && rule.freq != "YEARLY" {
+		filtered := candidates[:0]
+		for _, d := range candidates {
+			if rule.byMonth[int(d.Month())] {
+				filtered = append(filtered, d)
+			}
+		}
+		candidates = filtered
+	}
+
+	return applyBySetPos(candidates, rule.bySetPos)
+}
+
+// applyBySetPos выбирает из кандидатов периода только позиции,
+// перечисленные в BYSETPOS (1-based, отрицательные — с конца).
+func applyBySetPos(candidates []time.Time, setPos []int) []time.Time {
+	if len(setPos) == 0 {
+		return candidates
+	}
+	n := len(candidates)
+	var out []time.Time
+	for _, pos := range setPos {
+		idx := pos - 1
+		if pos < 0 {
+			idx = n + pos
+		}
+		if idx >= 0 && idx < n {
+			out = append(out, candidates[idx])
+		}
+	}
+	return out
+}
+
+func periodStartFor(anchor time.Time, rule rrule) time.Time {
+	switch rule.freq {
+	case "WEEKLY":
+		return weekStartWithWkst(anchor, rule.wkst)
+	case "MONTHLY":
+		return time.Date(anchor.Year(), anchor.Month(), 1, 0, 0, 0, 0, anchor.Location())
+	case "YEARLY":
+		return time.Date(anchor.Year(), 1, 1, 0, 0, 0, 0, anchor.Location())
+	default: // DAILY
+		return time.Date(anchor.Year(), anchor.Month(), anchor.Day(), 0, 0, 0, 0, anchor.Location())
+	}
+}
+
+func advancePeriod(periodStart time.Time, rule rrule) time.Time {
+	switch rule.freq {
+	case "WEEKLY":
+		return periodStart.AddDate(0, 0, 7*rule.interval)
+	case "MONTHLY":
+		return periodStart.AddDate(0, rule.interval, 0)
+	case "YEARLY":
+		return periodStart.AddDate(rule.interval, 0, 0)
+	default: // DAILY
+		return periodStart.AddDate(0, 0, rule.interval)
+	}
+}
+
+// nextRRuleDate ищет первое вхождение rule строго после now, считая
+// occurrence'ы от anchor (исходной даты задачи). Возвращает ErrSeriesEnded,
+// если следующее вхождение выпадает за UNTIL либо превышает COUNT.
+func nextRRuleDate(rule rrule, anchor, now time.Time) (string, error) {
+	periodStart := periodStartFor(anchor, rule)
+
+	occurrence := 0
+	for i := 0; i < maxRRuleOccurrences; i++ {
+		for _, day := range rule.periodCandidates(anchor, periodStart) {
+			if day.Before(anchor) {
+				continue
+			}
+			occurrence++
+			if !rule.until.IsZero() && day.After(rule.until) {
+				return "", ErrSeriesEnded
+			}
+			if rule.count > 0 && occurrence > rule.count {
+				return "", ErrSeriesEnded
+			}
+			if day.After(now) {
+				return day.Format(DateFormat), nil
+			}
+		}
+		periodStart = advancePeriod(periodStart, rule)
+	}
+	return "", fmt.Errorf("не удалось подобрать дату по RRULE за %d периодов", maxRRuleOccurrences)
+}
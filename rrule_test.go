@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNextDateRRuleMonthlyLastDay(t *testing.T) {
+	now, _ := time.Parse(DateFormat, "20260726")
+	got, err := NextDate(now, "20260115", "RRULE:FREQ=MONTHLY;BYMONTHDAY=-1;INTERVAL=2")
+	if err != nil {
+		t.Fatalf("NextDate вернул ошибку: %v", err)
+	}
+	if got != "20260731" {
+		t.Errorf("NextDate = %s, ожидали 20260731", got)
+	}
+}
+
+func TestNextDateRRuleWeeklyByDay(t *testing.T) {
+	now, _ := time.Parse(DateFormat, "20260101")
+	got, err := NextDate(now, "20260105", "FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=200")
+	if err != nil {
+		t.Fatalf("NextDate вернул ошибку: %v", err)
+	}
+	if got != "20260105" {
+		t.Errorf("NextDate = %s, ожидали 20260105", got)
+	}
+}
+
+func TestNextDateRRuleBySetPos(t *testing.T) {
+	// "каждый второй вторник нечётных месяцев".
+	now, _ := time.Parse(DateFormat, "20260726")
+	got, err := NextDate(now, "20260101", "RRULE:FREQ=MONTHLY;BYDAY=TU;BYSETPOS=2;BYMONTH=1,3,5,7,9,11")
+	if err != nil {
+		t.Fatalf("NextDate вернул ошибку: %v", err)
+	}
+	if got != "20260908" {
+		t.Errorf("NextDate = %s, ожидали 20260908", got)
+	}
+}
+
+func TestNextDateRRuleCountExhausted(t *testing.T) {
+	now, _ := time.Parse(DateFormat, "20260726")
+	_, err := NextDate(now, "20260105", "FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=10")
+	if !errors.Is(err, ErrSeriesEnded) {
+		t.Errorf("ожидали ErrSeriesEnded, получили %v", err)
+	}
+}
+
+func TestNextDateRRuleUntilExhausted(t *testing.T) {
+	now, _ := time.Parse(DateFormat, "20260110")
+	_, err := NextDate(now, "20260101", "FREQ=DAILY;UNTIL=20260105")
+	if !errors.Is(err, ErrSeriesEnded) {
+		t.Errorf("ожидали ErrSeriesEnded, получили %v", err)
+	}
+}
+
+func TestParseRRuleRejectsMissingFreq(t *testing.T) {
+	if _, err := parseRRule("RRULE:INTERVAL=2"); err == nil {
+		t.Error("ожидали ошибку при отсутствии FREQ")
+	}
+}
+
+func TestIsRRule(t *testing.T) {
+	cases := map[string]bool{
+		"RRULE:FREQ=DAILY":     true,
+		"FREQ=WEEKLY;BYDAY=MO": true,
+		"d 1":                  false,
+		"w 1,3":                false,
+	}
+	for repeat, want := range cases {
+		if got := isRRule(repeat); got != want {
+			t.Errorf("isRRule(%q) = %v, ожидали %v", repeat, got, want)
+		}
+	}
+}
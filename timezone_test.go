@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleNextDateRespectsTZParam(t *testing.T) {
+	// Без tz (UTC по умолчанию в этой песочнице) и с tz=Etc/GMT+12
+	// (UTC-12) одна и та же пара дат даёт разный результат: смещение на
+	// 12 часов назад толкает полночь 20260115 на предыдущий календарный
+	// день, и очередное совпадение правила "d 1" сдвигается на сутки раньше.
+	noTZ := httptest.NewRequest(http.MethodGet, "/api/nextdate?now=20260120&date=20260115&repeat=d+1", nil)
+	wNoTZ := httptest.NewRecorder()
+	handleNextDate(wNoTZ, noTZ)
+	if wNoTZ.Code != http.StatusOK {
+		t.Fatalf("status = %d, тело: %s", wNoTZ.Code, wNoTZ.Body.String())
+	}
+	gotNoTZ := strings.TrimSpace(wNoTZ.Body.String())
+	if gotNoTZ != "20260121" {
+		t.Errorf("без tz: got %s, ожидали 20260121", gotNoTZ)
+	}
+
+	withTZ := httptest.NewRequest(http.MethodGet, "/api/nextdate?now=20260120&date=20260115&repeat=d+1&tz=Etc/GMT%2B12", nil)
+	wTZ := httptest.NewRecorder()
+	handleNextDate(wTZ, withTZ)
+	if wTZ.Code != http.StatusOK {
+		t.Fatalf("status = %d, тело: %s", wTZ.Code, wTZ.Body.String())
+	}
+	gotTZ := strings.TrimSpace(wTZ.Body.String())
+	if gotTZ != "20260120" {
+		t.Errorf("с tz=Etc/GMT+12: got %s, ожидали 20260120", gotTZ)
+	}
+}
+
+func TestHandleNextDateRejectsUnknownTZ(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/nextdate?now=20260120&date=20260115&repeat=d+1&tz=Not/AZone", nil)
+	w := httptest.NewRecorder()
+	handleNextDate(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, ожидали 400", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "error") {
+		t.Errorf("ожидали JSON с полем error, получили: %s", w.Body.String())
+	}
+}
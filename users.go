@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// RoleAdmin/RoleUser — единственные роли, которые сейчас понимает сервер.
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// User — запись из таблицы users.
+type User struct {
+	ID           int64     `json:"id"`
+	Login        string    `json:"login"`
+	PasswordHash string    `json:"-"`
+	Role         string    `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func createUsersTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			login VARCHAR(255) UNIQUE NOT NULL,
+			password_hash VARCHAR(255) NOT NULL,
+			role VARCHAR(20) NOT NULL DEFAULT 'user',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);`)
+	return err
+}
+
+// migrateSchedulerUserID добавляет в scheduler колонку user_id, если её ещё
+// нет. sqlite не поддерживает "ADD COLUMN IF NOT EXISTS", поэтому сначала
+// проверяем pragma table_info.
+func migrateSchedulerUserID(db *sql.DB) error {
+	rows, err := db.Query(`PRAGMA table_info(scheduler)`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			ctype      string
+			notnull    int
+			dfltValue  any
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dfltValue, &primaryKey); err != nil {
+			return err
+		}
+		if name == "user_id" {
+			return nil // колонка уже есть
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`ALTER TABLE scheduler ADD COLUMN user_id INTEGER`)
+	return err
+}
+
+func createUser(db *sql.DB, login, password, role string) (*User, error) {
+	if login == "" || password == "" {
+		return nil, errors.New("логин и пароль обязательны")
+	}
+	if role == "" {
+		role = RoleUser
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := db.Exec(`INSERT INTO users (login, password_hash, role) VALUES (?, ?, ?)`,
+		login, string(hash), role)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return &User{ID: id, Login: login, Role: role}, nil
+}
+
+func findUserByLogin(db *sql.DB, login string) (*User, error) {
+	var u User
+	var createdAt string
+	err := db.QueryRow(`SELECT id, login, password_hash, role, created_at FROM users WHERE login = ?`, login).
+		Scan(&u.ID, &u.Login, &u.PasswordHash, &u.Role, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// authenticateUser ищет пользователя по логину и сверяет пароль с bcrypt-
+// хешем. Единая ошибка и на "нет такого логина", и на "неверный пароль" —
+// чтобы не давать атакующему понять, какой из двух случаев произошёл.
+func authenticateUser(db *sql.DB, login, password string) (*User, error) {
+	u, err := findUserByLogin(db, login)
+	if err != nil {
+		return nil, errors.New("неверный логин или пароль")
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+		return nil, errors.New("неверный логин или пароль")
+	}
+	return u, nil
+}
+
+func listUsers(db *sql.DB) ([]User, error) {
+	rows, err := db.Query(`SELECT id, login, role, created_at FROM users ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var u User
+		var createdAt string
+		if err := rows.Scan(&u.ID, &u.Login, &u.Role, &createdAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func deleteUser(db *sql.DB, id int64) (bool, error) {
+	res, err := db.Exec(`DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// authenticatedUser — то, что auth() кладёт в context.Context после успешной
+// проверки токена. ID == 0 означает «однопользовательский режим» (старый
+// вход по общему паролю без логина) — в этом случае задачи не фильтруются
+// по user_id, как и раньше.
+type authenticatedUser struct {
+	ID   int64
+	Role string
+}
+
+type contextKey string
+
+const userContextKey contextKey = "authenticatedUser"
+
+func withAuthenticatedUser(r *http.Request, u authenticatedUser) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), userContextKey, u))
+}
+
+func userFromContext(r *http.Request) (authenticatedUser, bool) {
+	u, ok := r.Context().Value(userContextKey).(authenticatedUser)
+	return u, ok
+}
+
+// scopedUserID возвращает user_id, по которому нужно фильтровать задачи
+// текущего запроса. scoped=false означает однопользовательский режим
+// (нет таблицы users в игре — общий пароль или её не было вовсе), в
+// котором задачи по-прежнему общие для всех, как до этого чанка.
+func scopedUserID(r *http.Request) (id int64, scoped bool) {
+	u, ok := userFromContext(r)
+	if !ok || u.ID <= 0 {
+		return 0, false
+	}
+	return u.ID, true
+}
+
+// handleSignup — публичная самостоятельная регистрация (в отличие от
+// /api/users, которую может вызывать только администратор). Новый
+// пользователь всегда получает роль RoleUser — повысить до admin можно
+// только через /api/users.
+func handleSignup(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Метод не поддерживается"})
+			return
+		}
+
+		var req createUserRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка десериализации JSON"})
+			return
+		}
+
+		user, err := createUser(db, strings.TrimSpace(req.Login), req.Password, RoleUser)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		token, err := makeUserJWT(user)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка генерации токена"})
+			return
+		}
+		json.NewEncoder(w).Encode(SigninResponse{Token: token})
+	}
+}
+
+// ===========================
+// /api/users — admin-only CRUD
+// ===========================
+
+type createUserRequest struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+	Role     string `json:"role,omitempty"`
+}
+
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	u, ok := userFromContext(r)
+	if !ok || u.Role != RoleAdmin {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Требуются права администратора"})
+		return false
+	}
+	return true
+}
+
+func handleUsersCollection(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if !requireAdmin(w, r) {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			users, err := listUsers(db)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка получения списка пользователей"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"users": users})
+
+		case http.MethodPost:
+			var req createUserRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка десериализации JSON"})
+				return
+			}
+			user, err := createUser(db, strings.TrimSpace(req.Login), req.Password, req.Role)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+				return
+			}
+			json.NewEncoder(w).Encode(user)
+
+		case http.MethodDelete:
+			idStr := r.URL.Query().Get("id")
+			id, err := strconv.ParseInt(idStr, 10, 64)
+			if err != nil || id <= 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Некорректный id пользователя"})
+				return
+			}
+			deleted, err := deleteUser(db, id)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Ошибка удаления пользователя"})
+				return
+			}
+			if !deleted {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Пользователь не найден"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{})
+
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Метод не поддерживается"})
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func setupMultiUserTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := createSchedulerTable(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := createUsersTable(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateSchedulerUserID(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// setupTestKeyStore даёт тесту свежий, изолированный keyStore (ключи
+// подписи JWT хранятся в файле рядом с рабочей директорией процесса) и
+// восстанавливает глобальное состояние после теста. Нужно вызвать один
+// раз на тест — до первого tokenForUser; повторная подмена keyStore
+// внутри одного теста "осиротила" бы kid уже выпущенных токенов.
+func setupTestKeyStore(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	keyStoreOnce = sync.Once{}
+	keyStore = nil
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		keyStoreOnce = sync.Once{}
+		keyStore = nil
+	})
+}
+
+// tokenForUser выпускает валидный токен приложения для пользователя, не
+// проходя через handleSignin — изолирует тест от конкретного формата
+// запроса /api/signin. Требует, чтобы тест уже вызвал setupTestKeyStore.
+func tokenForUser(t *testing.T, u *User) string {
+	t.Helper()
+	token, err := makeUserJWT(u)
+	if err != nil {
+		t.Fatalf("makeUserJWT вернул ошибку: %v", err)
+	}
+	return token
+}
+
+func addTaskAs(t *testing.T, db *sql.DB, token, title string) int {
+	t.Helper()
+	body := strings.NewReader(`{"date":"20260101","title":"` + title + `"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/task", body)
+	req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	rr := httptest.NewRecorder()
+
+	repo := NewTaskRepository(db, dbDriverSQLite)
+	auth(func(w http.ResponseWriter, r *http.Request) {
+		handleAddTask(w, r, repo)
+	}).ServeHTTP(rr, req)
+
+	var resp TaskResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("ошибка разбора ответа handleAddTask: %v (тело: %s)", err, rr.Body.String())
+	}
+	if resp.Error != "" {
+		t.Fatalf("handleAddTask вернул ошибку: %s", resp.Error)
+	}
+	return resp.ID
+}
+
+// TestMultiUserTaskIsolation проверяет, что один пользователь не видит и
+// не может изменять задачи другого — основное требование этого чанка.
+func TestMultiUserTaskIsolation(t *testing.T) {
+	os.Setenv("TODO_PASSWORD", "1234")
+	defer os.Unsetenv("TODO_PASSWORD")
+
+	setupTestKeyStore(t)
+	db := setupMultiUserTestDB(t)
+
+	alice, err := createUser(db, "alice", "pass1", RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := createUser(db, "bob", "pass2", RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aliceToken := tokenForUser(t, alice)
+	bobToken := tokenForUser(t, bob)
+
+	aliceTaskID := addTaskAs(t, db, aliceToken, "Alice's task")
+	repo := NewTaskRepository(db, dbDriverSQLite)
+
+	// Боб не должен видеть задачу Алисы в списке
+	req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: bobToken})
+	rr := httptest.NewRecorder()
+	auth(func(w http.ResponseWriter, r *http.Request) {
+		handleGetTasks(w, r, repo)
+	}).ServeHTTP(rr, req)
+
+	var listResp struct {
+		Tasks []Task `json:"tasks"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &listResp); err != nil {
+		t.Fatalf("ошибка разбора списка задач: %v (тело: %s)", err, rr.Body.String())
+	}
+	for _, task := range listResp.Tasks {
+		if task.ID == aliceTaskID {
+			t.Error("bob не должен видеть задачу alice в /api/tasks")
+		}
+	}
+
+	// Боб не должен суметь получить задачу Алисы по id
+	req = httptest.NewRequest(http.MethodGet, "/api/task?id="+strconv.Itoa(aliceTaskID), nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: bobToken})
+	rr = httptest.NewRecorder()
+	auth(func(w http.ResponseWriter, r *http.Request) {
+		handleGetTask(w, r, repo)
+	}).ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("ожидали 404 при чужом id, получили %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Боб не должен суметь удалить задачу Алисы
+	req = httptest.NewRequest(http.MethodDelete, "/api/task?id="+strconv.Itoa(aliceTaskID), nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: bobToken})
+	rr = httptest.NewRecorder()
+	auth(func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteTask(w, r, repo)
+	}).ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("ожидали 404 при попытке удалить чужую задачу, получили %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Сама Алиса по-прежнему должна видеть свою задачу
+	req = httptest.NewRequest(http.MethodGet, "/api/task?id="+strconv.Itoa(aliceTaskID), nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: aliceToken})
+	rr = httptest.NewRecorder()
+	auth(func(w http.ResponseWriter, r *http.Request) {
+		handleGetTask(w, r, repo)
+	}).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("alice должна видеть свою задачу, получили %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+// TestHandleSignup проверяет, что через публичную регистрацию можно
+// завести нового пользователя, и что он получает роль RoleUser, а не
+// любую роль, которую попытается передать в запросе.
+func TestHandleSignup(t *testing.T) {
+	db := setupMultiUserTestDB(t)
+	handler := handleSignup(db)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/signup", strings.NewReader(`{"login":"carol","password":"pass3","role":"admin"}`))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp SigninResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("ошибка разбора ответа: %v", err)
+	}
+	if resp.Token == "" {
+		t.Fatal("ожидали токен после успешной регистрации")
+	}
+
+	user, err := findUserByLogin(db, "carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != RoleUser {
+		t.Errorf("самостоятельная регистрация не должна давать роль %q, ожидали %q", user.Role, RoleUser)
+	}
+}
+
+// TestHandleUsersCollectionRequiresAdmin проверяет, что /api/users
+// доступен только администратору.
+func TestHandleUsersCollectionRequiresAdmin(t *testing.T) {
+	os.Setenv("TODO_PASSWORD", "1234")
+	defer os.Unsetenv("TODO_PASSWORD")
+
+	setupTestKeyStore(t)
+	db := setupMultiUserTestDB(t)
+
+	admin, err := createUser(db, "root", "pass1", RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := createUser(db, "plain", "pass2", RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adminToken := tokenForUser(t, admin)
+	userToken := tokenForUser(t, user)
+
+	handler := auth(handleUsersCollection(db))
+
+	t.Run("обычный пользователь => 403", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		req.AddCookie(&http.Cookie{Name: "token", Value: userToken})
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("ожидали 403, получили %d: %s", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("администратор => 200", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		req.AddCookie(&http.Cookie{Name: "token", Value: adminToken})
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("ожидали 200, получили %d: %s", rr.Code, rr.Body.String())
+		}
+	})
+}